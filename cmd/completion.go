@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/shell"
+	"github.com/spf13/cobra"
+)
+
+var completionInstall bool
+
+// supportedShells are the shells bii can configure
+var supportedShells = []string{"bash", "zsh", "fish", "nu", "elvish", "xonsh", "tcsh", "csh", "ksh", "mksh", "sh", "dash", "pwsh"}
+
+// archiveExtensions returns the file extensions offered when completing
+// archive arguments, for every registered format
+func archiveExtensions() []string {
+	var exts []string
+	seen := make(map[string]bool)
+	for _, f := range archive.Formats() {
+		for _, ext := range f.Extensions() {
+			// Shells filter on the last extension only, e.g. "gz" for ".tar.gz"
+			ext = strings.TrimPrefix(filepath.Ext(ext), ".")
+			if ext != "" && !seen[ext] {
+				seen[ext] = true
+				exts = append(exts, ext)
+			}
+		}
+	}
+	return exts
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion <bash|zsh|fish>",
+	Short: "Generate shell completion scripts",
+	Long: `Generate the completion script for bii for the given shell.
+
+Load completions in the current session:
+  bash: source <(bii completion bash)
+  zsh:  source <(bii completion zsh)
+  fish: bii completion fish | source
+
+Or let bii set them up permanently:
+  bii completion bash --install`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	RunE:      runCompletion,
+}
+
+func init() {
+	completionCmd.Flags().BoolVar(&completionInstall, "install", false, "Install the completion script and load it from the shell configuration")
+}
+
+func runCompletion(cmd *cobra.Command, args []string) error {
+	sh := args[0]
+
+	var buf bytes.Buffer
+	if err := generateCompletion(sh, &buf); err != nil {
+		return err
+	}
+
+	if !completionInstall {
+		_, err := cmd.OutOrStdout().Write(buf.Bytes())
+		return err
+	}
+
+	release, err := acquireLock(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+	
+	scriptPath, err := shell.InstallCompletion(sh, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to install completion: %w", err)
+	}
+
+	configFile, err := shell.GetShellConfigPath(sh)
+	if err != nil {
+		return err
+	}
+
+	notef("✅ Completion script written to %s\n", scriptPath)
+	notef("📝 Loaded from %s\n", configFile)
+	notef("💡 Restart your shell to enable completions\n")
+
+	return nil
+}
+
+func generateCompletion(sh string, buf *bytes.Buffer) error {
+	switch sh {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(buf, true)
+	case "zsh":
+		return rootCmd.GenZshCompletion(buf)
+	case "fish":
+		return rootCmd.GenFishCompletion(buf, true)
+	default:
+		return fmt.Errorf("%w: %s", shell.ErrUnsupportedShell, sh)
+	}
+}
+
+// completeArchives completes the archive argument with supported archive
+// files; install takes any number of them
+func completeArchives(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 && cmd.Name() != "install" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return archiveExtensions(), cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeShells completes the --shell flag
+func completeShells(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return supportedShells, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeOutputFormats completes the --output flag
+func completeOutputFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"text", "json", "yaml"}, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/repoleved08/bii/pkg/config"
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// cfg holds the defaults from the config file and BII_* variables
+	cfg = &config.Config{}
+	// cfgSources tells where each setting in cfg comes from
+	cfgSources map[string]config.Source
+	// conflictPolicy is the --conflict flag
+	conflictPolicy string
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change bii's defaults",
+	Long: `Show and change the defaults bii uses when a flag isn't given.
+
+Settings are stored in $XDG_CONFIG_HOME/bii/config.yaml (default
+~/.config/bii/config.yaml), or the file named by BII_CONFIG. Each can also be
+set with an environment variable, e.g. BII_DEST, which takes precedence over
+the file; flags take precedence over both.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigGet,
+	
+	ValidArgsFunction: completeConfigKeys,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> [value]",
+	Short: "Change a setting in the config file; without a value, unset it",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  runConfigSet,
+	
+	ValidArgsFunction: completeConfigKeys,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings with their values and where they come from",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigList,
+}
+
+func init() {
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configListCmd)
+}
+
+// loadConfig reads the config file and environment, and uses them for every
+// flag that wasn't given on the command line
+func loadConfig(cmd *cobra.Command) error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+	
+	c, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	
+	sources, err := c.ApplyEnv()
+	if err != nil {
+		return err
+	}
+	cfg, cfgSources = c, sources
+	
+	flags := cmd.Flags()
+	if !flags.Changed("output") && cfg.Output != "" {
+		outputFormat = cfg.Output
+	}
+	if !flags.Changed("shell") {
+		shellName = cfg.Shell
+	}
+	if !flags.Changed("skip-path") && cfg.SkipPath != nil {
+		skipPath = *cfg.SkipPath
+	}
+	if !flags.Changed("yes") && cfg.AssumeYes != nil {
+		forceYes = *cfg.AssumeYes
+	}
+	if !flags.Changed("conflict") {
+		conflictPolicy = cfg.Conflict
+	}
+	
+	return nil
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	value, err := cfg.Get(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+	
+	release, err := acquireLock(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+	
+	// Only the file is changed, not what the environment overrides
+	c, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	
+	value := ""
+	if len(args) == 2 {
+		value = args[1]
+	}
+	if err := c.Set(args[0], value); err != nil {
+		return err
+	}
+	if err := c.Save(path); err != nil {
+		return err
+	}
+	
+	if env := config.Env(args[0]); env != "" && os.Getenv(env) != "" {
+		logger.Warn(fmt.Sprintf("%s is set and overrides the config file", env))
+	}
+	
+	if value == "" {
+		notef("✅ Unset %s in %s\n", args[0], path)
+	} else {
+		notef("✅ Set %s = %s in %s\n", args[0], value, path)
+	}
+	return nil
+}
+
+func runConfigList(cmd *cobra.Command, args []string) error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("# %s\n", path)
+	
+	for _, key := range config.Keys() {
+		value, err := cfg.Get(key)
+		if err != nil {
+			return err
+		}
+		
+		source := string(cfgSources[key])
+		if cfgSources[key] == config.SourceEnv {
+			source = config.Env(key)
+		}
+		fmt.Printf("%-10s = %-30s # %s\n", key, value, source)
+	}
+	
+	return nil
+}
+
+// completeConfigKeys completes the key argument of config get and set
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return config.Keys(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// cacheDir returns where downloaded archives are kept
+func cacheDir() (string, error) {
+	if cfg.CacheDir != "" {
+		return expandPath(cfg.CacheDir)
+	}
+	return installer.DefaultCacheDir()
+}
+
+// expandPath expands a leading ~ and makes path absolute
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = home + path[1:]
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/bii"
+	"github.com/repoleved08/bii/pkg/flock"
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/prompt"
+	"github.com/repoleved08/bii/pkg/shell"
+)
+
+// Exit codes, documented in the README. Scripts rely on them, so codes are
+// only ever added.
+const (
+	exitOK           = 0
+	exitError        = 1
+	exitUsage        = 2
+	exitNotFound     = 3
+	exitBadArchive   = 4
+	exitNoBinaries   = 5
+	exitCancelled    = 6
+	exitVerification = 7
+	exitConflict     = 8
+	exitLocked       = 9
+	exitDownload     = 10
+	exitShell        = 11
+	exitNeedsRoot    = 12
+)
+
+// exitCodes maps errors to exit codes. The first match wins.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{bii.ErrNotFound, exitNotFound},
+	{archive.ErrUnsupportedFormat, exitBadArchive},
+	{archive.ErrCorrupt, exitBadArchive},
+	{archive.ErrNoBinaries, exitNoBinaries},
+	{installer.ErrBinaryNotFound, exitNoBinaries},
+	{prompt.ErrDeclined, exitCancelled},
+	{prompt.ErrNoAnswer, exitCancelled},
+	{prompt.ErrNonInteractive, exitCancelled},
+	{context.Canceled, exitCancelled},
+	{installer.ErrChecksumMismatch, exitVerification},
+	{installer.ErrLockMismatch, exitVerification},
+	{installer.ErrExists, exitConflict},
+	{installer.ErrConflict, exitConflict},
+	{flock.ErrLocked, exitLocked},
+	{installer.ErrDownload, exitDownload},
+	{shell.ErrUnsupportedShell, exitShell},
+	{shell.ErrNoShell, exitShell},
+	{shell.ErrUnsupportedDir, exitShell},
+	{installer.ErrNeedsRoot, exitNeedsRoot},
+}
+
+// usageError is a mistake in how bii was run, such as an unknown flag or a
+// bad flag value
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func (e usageError) Unwrap() error { return e.err }
+
+// usageErrorf returns a usageError with a formatted message
+func usageErrorf(format string, a ...interface{}) error {
+	return usageError{fmt.Errorf(format, a...)}
+}
+
+// archivesFailed is returned when some of several archives failed
+type archivesFailed struct {
+	total int
+	errs  []error
+}
+
+func (e *archivesFailed) Error() string {
+	return fmt.Sprintf("%d of %d archives failed", len(e.errs), e.total)
+}
+
+func (e *archivesFailed) Unwrap() []error { return e.errs }
+
+// exitCode returns the exit code for err
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var usage usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+
+	// Failed archives share a code only if they all failed the same way
+	var failed *archivesFailed
+	if errors.As(err, &failed) {
+		code := exitCode(failed.errs[0])
+		for _, e := range failed.errs[1:] {
+			if exitCode(e) != code {
+				return exitError
+			}
+		}
+		return code
+	}
+
+	for _, c := range exitCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return exitError
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/bii"
+	"github.com/repoleved08/bii/pkg/flock"
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/prompt"
+	"github.com/repoleved08/bii/pkg/shell"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// TestMain keeps commands run by tests away from the user's configuration
+// and state
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "bii-cmd-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", dir)
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	os.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"success", nil, 0},
+		{"other error", errors.New("boom"), 1},
+		{"usage", usageErrorf("unknown flag"), 2},
+		{"usage wrapping a known error", usageError{bii.ErrNotFound}, 2},
+		{"not found", fmt.Errorf("x.tar.gz: %w", bii.ErrNotFound), 3},
+		{"unsupported format", archive.ErrUnsupportedFormat, 4},
+		{"corrupt", archive.ErrCorrupt, 4},
+		{"no binaries", archive.ErrNoBinaries, 5},
+		{"listed binary missing", fmt.Errorf("t: %w: tool", installer.ErrBinaryNotFound), 5},
+		{"declined", bii.ErrCancelled, 6},
+		{"no answer", prompt.ErrNoAnswer, 6},
+		{"non-interactive", prompt.ErrNonInteractive, 6},
+		{"interrupted", fmt.Errorf("interrupted: %w", context.Canceled), 6},
+		{"checksum mismatch", fmt.Errorf("%w: %w", bii.ErrRefused, installer.ErrChecksumMismatch), 7},
+		{"lockfile mismatch", installer.ErrLockMismatch, 7},
+		{"exists", fmt.Errorf("%w: %w", bii.ErrRefused, installer.ErrExists), 8},
+		{"conflict", fmt.Errorf("%w: %w", bii.ErrRefused, installer.ErrConflict), 8},
+		{"shared by tools", fmt.Errorf("sync failed: t: %w: x is also installed by u", installer.ErrConflict), 8},
+		{"locked", flock.ErrLocked, 9},
+		{"download", installer.ErrDownload, 10},
+		{"unsupported shell", shell.ErrUnsupportedShell, 11},
+		{"no shell", shell.ErrNoShell, 11},
+		{"unsupported directory", fmt.Errorf("%w for tcsh: \"/it's\"", shell.ErrUnsupportedDir), 11},
+		{"needs root", installer.ErrNeedsRoot, 12},
+		{
+			"archives failed the same way",
+			&archivesFailed{total: 3, errs: []error{bii.ErrNotFound, fmt.Errorf("b: %w", bii.ErrNotFound)}},
+			3,
+		},
+		{
+			"archives failed differently",
+			&archivesFailed{total: 3, errs: []error{bii.ErrNotFound, archive.ErrCorrupt}},
+			1,
+		},
+		{
+			"one archive failed",
+			&archivesFailed{total: 2, errs: []error{archive.ErrNoBinaries}},
+			5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := exitCode(tt.err); code != tt.code {
+				t.Errorf("expected exit code %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestExecuteUsage(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.tar.gz")
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"unknown command", []string{"no-such-command"}, exitUsage},
+		{"unknown flag", []string{"install", "--no-such-flag"}, exitUsage},
+		{"missing arguments", []string{"install"}, exitUsage},
+		// Once a command runs, its errors keep their own code
+		{"missing archive", []string{"install", "--dry-run", missing}, exitNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := execute(t, tt.args...); code != tt.code {
+				t.Errorf("expected exit code %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
+
+// execute runs bii with args as if it was just started and returns the
+// exit code
+func execute(t *testing.T, args ...string) int {
+	t.Helper()
+
+	reset(rootCmd)
+	started = false
+	rootCmd.SetArgs(args)
+	t.Cleanup(func() { rootCmd.SetArgs(nil) })
+
+	return Execute()
+}
+
+// reset clears what an earlier run left in cmd and its subcommands: the
+// flags it set, and the context Execute cancelled on return
+func reset(cmd *cobra.Command) {
+	cmd.SetContext(nil)
+	for _, flags := range []*pflag.FlagSet{cmd.Flags(), cmd.PersistentFlags()} {
+		flags.VisitAll(func(f *pflag.Flag) {
+			f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+	}
+	for _, c := range cmd.Commands() {
+		reset(c)
+	}
+}
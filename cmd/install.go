@@ -0,0 +1,589 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/bii"
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/manifest"
+	"github.com/repoleved08/bii/pkg/progress"
+	"github.com/repoleved08/bii/pkg/prompt"
+	"github.com/repoleved08/bii/pkg/shell"
+	"github.com/spf13/cobra"
+)
+
+// jobs is the --jobs flag
+var jobs int
+
+// stdinArchive is the archive argument that reads the archive from stdin
+const stdinArchive = "-"
+
+// probeVersion is the --probe-version flag of install and inspect
+var probeVersion bool
+
+const (
+	probeVersionFlag  = "probe-version"
+	probeVersionUsage = "Run each binary with --version, version or -V to find its version"
+)
+
+var installCmd = &cobra.Command{
+	Use:   "install <archive>...",
+	Short: "Install binaries from one or more archives",
+	Long: `Install the binaries found in one or more archives.
+
+Archives can be given as glob patterns, e.g. "dist/*.tar.gz", or as "-" to
+read one from stdin, e.g. "curl -sL <url> | bii install -y -". Several
+archives are inspected and extracted in parallel, confirmed once, and PATH is
+configured once for all of them. If any archive fails, the others are still
+installed and bii exits with an error.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runInstall,
+
+	ValidArgsFunction: completeArchives,
+}
+
+func init() {
+	installCmd.Flags().StringVarP(&destDir, "dest", "d", "", "Destination directory (default: ~/.local/bin)")
+	installCmd.Flags().BoolVarP(&skipPath, "skip-path", "s", false, "Skip PATH configuration")
+	installCmd.Flags().BoolVarP(&forceYes, "yes", "y", false, "Skip confirmation prompts (also set by BII_ASSUME_YES=1)")
+	installCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be installed and changed without touching the filesystem")
+	installCmd.Flags().BoolVar(&system, "system", false, "Install for all users to "+installer.SystemDestDir+" with sudo or doas, and set up PATH in /etc")
+	installCmd.Flags().StringVar(&conflictPolicy, "conflict", "", "What to do with files that already exist: overwrite, skip or fail (default: overwrite)")
+	installCmd.RegisterFlagCompletionFunc("conflict", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"overwrite", "skip", "fail"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	installCmd.Flags().BoolVar(&frozen, "frozen", false, "Refuse to install unless the archive and binaries match the lockfile")
+	installCmd.Flags().StringVar(&lockFile, "lockfile", manifest.LockFileName, "Lockfile used by --frozen")
+	installCmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "Number of archives to process at once (default: number of CPUs)")
+	installCmd.Flags().BoolVar(&probeVersion, probeVersionFlag, false, probeVersionUsage)
+}
+
+// archiveJob is an archive being installed
+type archiveJob struct {
+	path string
+	// plan is set once the archive was read
+	plan   *bii.Plan
+	result installResult
+	// warnings are printed once the job is reported, to keep the output of
+	// parallel jobs in order
+	warnings []string
+	err      error
+}
+
+// binaries returns the binaries the job installs
+func (j *archiveJob) binaries() []archive.Entry {
+	if j.plan == nil {
+		return nil
+	}
+	return j.plan.Binaries
+}
+
+func runInstall(cmd *cobra.Command, args []string) error {
+	paths, err := expandArchives(args)
+	if err != nil {
+		return err
+	}
+	if err := checkStdinConfirmable(paths); err != nil {
+		return err
+	}
+
+	// Set default destination
+	if destDir == "" && system {
+		destDir = installer.SystemDestDir
+	}
+	if destDir == "" {
+		dir, err := defaultDestDir()
+		if err != nil {
+			return err
+		}
+		destDir = dir
+	}
+
+	single := len(paths) == 1
+	bar := newProgressRenderer(!single)
+
+	inst, esc, err := newInstaller(bar.Func())
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+
+	if single {
+		printf("📦 Installing from: %s\n", paths[0])
+	} else {
+		printf("📦 Installing from %d archives\n", len(paths))
+	}
+	printf("📁 Destination: %s\n\n", destDir)
+
+	archiveJobs := make([]*archiveJob, len(paths))
+	for i, path := range paths {
+		archiveJobs[i] = &archiveJob{path: path}
+	}
+
+	defer func() {
+		for _, j := range archiveJobs {
+			if j.plan != nil {
+				j.plan.Close()
+			}
+		}
+	}()
+
+	forEachJob(archiveJobs, jobs, func(j *archiveJob) {
+		prepareArchive(ctx, inst, j)
+	})
+	bar.Finish()
+	checkCollisions(archiveJobs)
+
+	var pending []*archiveJob
+	for _, j := range archiveJobs {
+		reportPrepared(j, single)
+		if j.err == nil && len(j.binaries()) > 0 {
+			pending = append(pending, j)
+		}
+	}
+
+	path := pathResult{Status: "skipped", ChangedFiles: []string{}}
+	var warnings []string
+
+	if len(pending) == 0 {
+		return finishInstall(archiveJobs, path, warnings)
+	}
+
+	if dryRun {
+		printLine("📋 Dry run, nothing will be changed")
+		for _, j := range pending {
+			if err := printFilePlan(destDir, j, single); err != nil {
+				return err
+			}
+		}
+		if !skipPath {
+			printLine()
+			if err := printPathPlan(ctx, inst, &path, &warnings); err != nil {
+				return err
+			}
+		}
+		return finishInstall(archiveJobs, path, warnings)
+	}
+
+	// Confirm once for every archive, and keep other bii processes out
+	// until PATH is configured
+	plans := make([]*bii.Plan, len(pending))
+	for i, j := range pending {
+		plans[i] = j.plan
+	}
+	if err := inst.Confirm(ctx, plans...); err != nil {
+		return err
+	}
+
+	release, err := inst.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// sudo and doas may ask for a password, which parallel jobs would garble
+	workers := jobs
+	if system && len(esc.Prefix) > 0 {
+		workers = 1
+	}
+
+	// Install binaries
+	forEachJob(pending, workers, func(j *archiveJob) {
+		installed, err := inst.Apply(ctx, j.plan)
+		if err != nil {
+			j.err = err
+			return
+		}
+		j.result.Installed = append(j.result.Installed, installed.Installed...)
+		j.result.Versions = installed.Versions
+		j.result.Warnings = append(j.result.Warnings, installed.Warnings...)
+	})
+	bar.Finish()
+
+	succeeded := 0
+	for _, j := range pending {
+		if j.err != nil {
+			// Execute reports the error of a single archive
+			if !single {
+				printf("\n❌ %s: %v\n", j.path, j.err)
+			}
+			continue
+		}
+		succeeded++
+
+		if single {
+			printf("\n✅ Successfully installed %d binary(ies) to %s\n", len(j.result.Installed), destDir)
+		} else {
+			printf("\n✅ %s: installed %d binary(ies)\n", j.path, len(j.result.Installed))
+		}
+		for _, bin := range j.result.Installed {
+			printf("  • %s%s\n", filepath.Base(bin), versionSuffix(j.result.Versions[bin]))
+		}
+	}
+	// Handle PATH configuration, once for every archive
+	if succeeded == 0 {
+		return finishInstall(archiveJobs, path, warnings)
+	}
+	if !skipPath {
+		printLine()
+		if err := applyPath(ctx, inst, &path); err != nil {
+			path.Status = "failed"
+			warnings = append(warnings, warnf("PATH configuration failed: %v", err))
+			printf("Please manually add to PATH: export PATH=\"%s:$PATH\"\n", destDir)
+		}
+	} else if inPath, _ := shell.IsInPath(destDir); !inPath {
+		warnings = append(warnings, fmt.Sprintf("%s is not in PATH", destDir))
+		printf("\n💡 %s is not in PATH. To set it up without editing files, add to your shell config:\n", destDir)
+		printf("   eval \"$(bii shell-init --dest %s)\"\n", destDir)
+	}
+
+	return finishInstall(archiveJobs, path, warnings)
+}
+
+// expandArchives expands glob patterns among the archive arguments and drops
+// duplicates. Arguments without glob characters are kept as given, so a
+// missing archive is reported for that archive.
+func expandArchives(args []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+
+	add := func(path string) {
+		if clean := filepath.Clean(path); !seen[clean] {
+			seen[clean] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == stdinArchive {
+			if seen[arg] {
+				return nil, usageErrorf("stdin can only be read once")
+			}
+			seen[arg] = true
+			paths = append(paths, arg)
+			continue
+		}
+		if !strings.ContainsAny(arg, "*?[") {
+			add(arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, usageErrorf("invalid pattern %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%w: no archives match %s", bii.ErrNotFound, arg)
+		}
+		for _, m := range matches {
+			add(m)
+		}
+	}
+
+	return paths, nil
+}
+
+// checkStdinConfirmable fails before an archive is read from stdin if the
+// installation would then have to be confirmed, since the answer can't come
+// from stdin too
+func checkStdinConfirmable(paths []string) error {
+	if dryRun {
+		return nil
+	}
+	for _, path := range paths {
+		if path != stdinArchive {
+			continue
+		}
+		if _, ok := newPrompter().(prompt.AssumeYes); !ok {
+			return fmt.Errorf("can't confirm the installation of an archive read from stdin: %w", prompt.ErrNonInteractive)
+		}
+	}
+	return nil
+}
+
+// forEachJob runs fn for every job, at most workers at a time
+func forEachJob(archiveJobs []*archiveJob, workers int, fn func(j *archiveJob)) {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, j := range archiveJobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(j *archiveJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(j)
+		}(j)
+	}
+	wg.Wait()
+}
+
+// prepareArchive reads an archive and decides which binaries to install,
+// applying the conflict policy and, with --frozen, checking the lockfile.
+// It doesn't print, so it can run in parallel.
+func prepareArchive(ctx context.Context, inst *bii.Installer, j *archiveJob) {
+	j.result = installResult{
+		SchemaVersion: schemaVersion,
+		Archive:       j.path,
+		Destination:   destDir,
+		DryRun:        dryRun,
+		Entries:       []entryResult{},
+		Binaries:      []string{},
+		Installed:     []string{},
+		Warnings:      []string{},
+	}
+
+	if j.path == stdinArchive {
+		j.plan, j.err = inst.PrepareReader(ctx, j.path, os.Stdin)
+	} else {
+		j.plan, j.err = inst.Prepare(ctx, j.path)
+	}
+	if j.plan == nil {
+		return
+	}
+
+	j.result.Format = j.plan.Format
+	j.result.Version = j.plan.Version
+	j.result.Entries = entryResults(j.plan.Entries)
+	j.result.Binaries = append(j.result.Binaries, j.plan.Names()...)
+	for _, path := range j.plan.Skipped {
+		j.warnings = append(j.warnings, fmt.Sprintf("skipping %s, it already exists", path))
+	}
+}
+
+// newInstaller returns the Installer for the install flags, sending progress
+// to fn. The escalator is only found for system installs that aren't dry
+// runs.
+func newInstaller(fn progress.Func) (*bii.Installer, installer.Escalator, error) {
+	var esc installer.Escalator
+
+	policy, err := installer.ParseConflictPolicy(conflictPolicy)
+	if err != nil {
+		return nil, esc, usageError{err}
+	}
+
+	opts := []bii.Option{
+		bii.WithDest(destDir),
+		bii.WithShell(shellName),
+		bii.WithPrompter(newPrompter()),
+		bii.WithConflictPolicy(policy),
+		bii.WithProgress(fn),
+		bii.WithLogger(logger),
+		bii.WithLockTimeout(lockWait),
+	}
+	if probeVersion {
+		opts = append(opts, bii.WithVersionProbe(0))
+	}
+	if frozen {
+		lock, err := loadFrozenLock(lockFile)
+		if err != nil {
+			return nil, esc, err
+		}
+		opts = append(opts, bii.WithLock(lock))
+	}
+	if system {
+		if !dryRun {
+			if esc, err = installer.FindEscalator(); err != nil {
+				return nil, esc, err
+			}
+		}
+		opts = append(opts, bii.WithSystem(esc))
+	}
+	if skipPath {
+		opts = append(opts, bii.WithSkipPath())
+	}
+	if dryRun {
+		opts = append(opts, bii.WithDryRun())
+	}
+
+	inst, err := bii.New(opts...)
+	return inst, esc, err
+}
+
+// checkCollisions fails archives that would install a file another archive
+// before them installs too
+func checkCollisions(archiveJobs []*archiveJob) {
+	owners := make(map[string]string)
+
+	for _, j := range archiveJobs {
+		if j.err != nil {
+			continue
+		}
+
+		for _, e := range j.binaries() {
+			name := filepath.Base(e.Name)
+			if owner, ok := owners[name]; ok && owner != j.path {
+				j.err = fmt.Errorf("%w: %s is also installed from %s", installer.ErrConflict, name, owner)
+				break
+			}
+		}
+		if j.err != nil {
+			continue
+		}
+
+		for _, e := range j.binaries() {
+			owners[filepath.Base(e.Name)] = j.path
+		}
+	}
+}
+
+// reportPrepared prints what was found in an archive
+func reportPrepared(j *archiveJob, single bool) {
+	for _, w := range j.warnings {
+		j.result.Warnings = append(j.result.Warnings, warnf("%s", w))
+	}
+
+	if j.err != nil {
+		// Execute reports the error of a single archive
+		if !single {
+			printf("❌ %s: %v\n\n", j.path, j.err)
+		}
+		return
+	}
+
+	binaries := j.binaries()
+	if len(binaries) == 0 {
+		printf("✅ Nothing to install from %s, every binary already exists\n\n", j.path)
+		return
+	}
+
+	if single {
+		printf("✅ Found %d executable(s):\n", len(binaries))
+	} else {
+		printf("✅ %s: found %d executable(s):\n", j.path, len(binaries))
+	}
+	if j.plan.Version != "" {
+		printf("📌 Version %s, from the file name\n", j.plan.Version)
+	}
+	for _, bin := range binaries {
+		printf("  • %s%s\n", bin.Name, platformSuffix(bin))
+	}
+	printLine()
+
+	if frozen {
+		printf("🔒 Archive and binaries match %s\n\n", lockFile)
+	}
+}
+
+// finishInstall writes the structured result and returns an error if any
+// archive failed. One archive gives the same document as before several
+// were supported, with its error set if it failed, and fails with the
+// archive's error.
+func finishInstall(archiveJobs []*archiveJob, path pathResult, warnings []string) error {
+	var errs []error
+	for _, j := range archiveJobs {
+		if j.err != nil {
+			errs = append(errs, j.err)
+			j.result.Error = j.err.Error()
+		}
+	}
+	failed := len(errs)
+
+	if len(archiveJobs) == 1 {
+		result := archiveJobs[0].result
+		result.Path = &path
+		result.Warnings = append(result.Warnings, warnings...)
+		if err := writeResult(result); err != nil {
+			return err
+		}
+	} else {
+		result := multiInstallResult{
+			SchemaVersion: schemaVersion,
+			Destination:   destDir,
+			DryRun:        dryRun,
+			Archives:      []installResult{},
+			Failed:        failed,
+			Path:          path,
+			Warnings:      append([]string{}, warnings...),
+		}
+		for _, j := range archiveJobs {
+			result.Archives = append(result.Archives, j.result)
+		}
+		if err := writeResult(result); err != nil {
+			return err
+		}
+	}
+
+	if len(archiveJobs) == 1 && failed > 0 {
+		return errs[0]
+	}
+	if failed > 0 {
+		return &archivesFailed{total: len(archiveJobs), errs: errs}
+	}
+	return nil
+}
+
+// printFilePlan prints the files installing an archive to dir would write
+// and records them in its result
+func printFilePlan(dir string, j *archiveJob, single bool) error {
+	planned, err := installer.Plan(dir, j.binaries())
+	if err != nil {
+		return err
+	}
+	if system {
+		// The final move sets these regardless of the archive
+		for i := range planned {
+			planned[i].Entry.Mode = 0755
+		}
+	}
+	j.result.Plan = planResults(planned)
+
+	printLine()
+	if single {
+		printLine("Files:")
+	} else {
+		printf("Files from %s:\n", j.path)
+	}
+	for _, p := range planned {
+		printf("  %s → %s (mode %04o, %d bytes, %s)\n", p.Entry.Name, p.Dest, p.Entry.Mode.Perm(), p.Entry.Size, p.Status)
+	}
+
+	return nil
+}
+
+// printPathPlan prints the PATH changes installing would make and records
+// them in result
+func printPathPlan(ctx context.Context, inst *bii.Installer, result *pathResult, warnings *[]string) error {
+	plan, err := inst.PlanPath(ctx)
+	if plan == nil {
+		result.Status = "failed"
+		*warnings = append(*warnings, warnf("PATH configuration would fail: %v", err))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result.Shell = plan.Shell
+
+	if plan.Status == bii.PathAlreadyConfigured {
+		result.Status = string(plan.Status)
+		if plan.Shell == "" {
+			printf("PATH: %s is already in PATH\n", inst.Dest())
+		} else {
+			printf("PATH: %s is already in PATH for %s\n", inst.Dest(), plan.Shell)
+		}
+		return nil
+	}
+
+	result.Status = string(plan.Status)
+	result.ChangedFiles = append(result.ChangedFiles, plan.ChangedFiles...)
+	result.Diff = plan.Diff
+	if plan.Shell == "" {
+		printLine("System PATH changes:")
+	} else {
+		printf("PATH changes for %s:\n", plan.Shell)
+	}
+	printf("%s", plan.Diff)
+
+	return nil
+}
@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/bii"
+	"github.com/repoleved08/bii/pkg/flock"
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/state"
+)
+
+// captureStdout returns what fn writes to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	f, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stdout := os.Stdout
+	os.Stdout = f
+	fn()
+	os.Stdout = stdout
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// jobWith returns a prepared job for path that installs names
+func jobWith(path string, names ...string) *archiveJob {
+	plan := &bii.Plan{Archive: path}
+	for _, name := range names {
+		plan.Binaries = append(plan.Binaries, archive.Entry{Name: "bin/" + name, Mode: 0755})
+	}
+	return &archiveJob{path: path, plan: plan}
+}
+
+func TestExpandArchives(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.tar.gz", "b.tar.gz", "c.zip"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := filepath.Join(dir, "a.tar.gz")
+	b := filepath.Join(dir, "b.tar.gz")
+	c := filepath.Join(dir, "c.zip")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		err      error
+		usage    bool
+	}{
+		{"plain paths", []string{c, a}, []string{c, a}, nil, false},
+		{"missing path kept", []string{filepath.Join(dir, "missing.zip")}, []string{filepath.Join(dir, "missing.zip")}, nil, false},
+		{"glob", []string{filepath.Join(dir, "*.tar.gz")}, []string{a, b}, nil, false},
+		{"duplicates dropped", []string{a, filepath.Join(dir, "*"), filepath.Join(dir, ".", "a.tar.gz")}, []string{a, b, c}, nil, false},
+		{"stdin", []string{"-", a}, []string{"-", a}, nil, false},
+		{"stdin twice", []string{"-", "-"}, nil, nil, true},
+		{"no match", []string{filepath.Join(dir, "*.rar")}, nil, bii.ErrNotFound, false},
+		{"bad pattern", []string{filepath.Join(dir, "[")}, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := expandArchives(tt.args)
+
+			var usage usageError
+			switch {
+			case tt.usage:
+				if !errors.As(err, &usage) {
+					t.Fatalf("expected a usage error, got %v", err)
+				}
+				return
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("expandArchives failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(paths, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, paths)
+			}
+		})
+	}
+}
+
+func TestForEachJob(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobs    int
+		workers int
+		max     int
+	}{
+		{"one worker", 5, 1, 1},
+		{"fewer workers than jobs", 8, 3, 3},
+		{"more workers than jobs", 2, 4, 2},
+		{"default workers", 3, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archiveJobs := make([]*archiveJob, tt.jobs)
+			for i := range archiveJobs {
+				archiveJobs[i] = &archiveJob{path: fmt.Sprint(i)}
+			}
+
+			var mu sync.Mutex
+			var running, peak int32
+			ran := make(map[string]bool)
+			forEachJob(archiveJobs, tt.workers, func(j *archiveJob) {
+				n := atomic.AddInt32(&running, 1)
+				mu.Lock()
+				if n > peak {
+					peak = n
+				}
+				ran[j.path] = true
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			})
+
+			if len(ran) != tt.jobs {
+				t.Errorf("expected %d jobs to run, got %d", tt.jobs, len(ran))
+			}
+			if int(peak) > tt.max {
+				t.Errorf("expected at most %d jobs at once, got %d", tt.max, peak)
+			}
+		})
+	}
+}
+
+func TestCheckCollisions(t *testing.T) {
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		jobs func() []*archiveJob
+		// failed are the jobs expected to fail, by path
+		failed []string
+	}{
+		{
+			"distinct binaries",
+			func() []*archiveJob { return []*archiveJob{jobWith("a", "x"), jobWith("b", "y")} },
+			nil,
+		},
+		{
+			"later archive fails",
+			func() []*archiveJob {
+				return []*archiveJob{jobWith("a", "x", "y"), jobWith("b", "z", "y"), jobWith("c", "z")}
+			},
+			[]string{"b"},
+		},
+		{
+			"failed archive claims nothing",
+			func() []*archiveJob {
+				j := jobWith("a", "x")
+				j.err = failed
+				return []*archiveJob{j, jobWith("b", "x")}
+			},
+			[]string{"a"},
+		},
+		{
+			"same file twice in one archive",
+			func() []*archiveJob { return []*archiveJob{jobWith("a", "x", "x")} },
+			nil,
+		},
+		{
+			"archive without a plan",
+			func() []*archiveJob { return []*archiveJob{{path: "a"}, jobWith("b", "x")} },
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archiveJobs := tt.jobs()
+			checkCollisions(archiveJobs)
+
+			var got []string
+			for _, j := range archiveJobs {
+				if j.err == nil {
+					continue
+				}
+				got = append(got, j.path)
+				if j.err != failed && !errors.Is(j.err, installer.ErrConflict) {
+					t.Errorf("%s: expected a conflict, got %v", j.path, j.err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.failed) {
+				t.Errorf("expected %v to fail, got %v", tt.failed, got)
+			}
+		})
+	}
+}
+
+func TestFinishInstall(t *testing.T) {
+	outputFormat = "json"
+	t.Cleanup(func() { outputFormat = "" })
+
+	notFound := fmt.Errorf("%w: b.zip", bii.ErrNotFound)
+	corrupt := fmt.Errorf("c.zip: %w", archive.ErrCorrupt)
+
+	tests := []struct {
+		name string
+		errs []error
+		// code is the exit code of the returned error
+		code int
+	}{
+		{"single archive", []error{nil}, exitOK},
+		{"single archive failed", []error{notFound}, exitNotFound},
+		{"several archives", []error{nil, nil}, exitOK},
+		{"one of several failed", []error{nil, notFound}, exitNotFound},
+		{"several failed the same way", []error{notFound, notFound}, exitNotFound},
+		{"several failed differently", []error{notFound, corrupt}, exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archiveJobs := make([]*archiveJob, len(tt.errs))
+			for i, err := range tt.errs {
+				archiveJobs[i] = &archiveJob{
+					path:   fmt.Sprintf("%d.zip", i),
+					result: installResult{Archive: fmt.Sprintf("%d.zip", i), Warnings: []string{}},
+					err:    err,
+				}
+			}
+
+			var err error
+			out := captureStdout(t, func() {
+				err = finishInstall(archiveJobs, pathResult{Status: "skipped"}, nil)
+			})
+			if code := exitCode(err); code != tt.code {
+				t.Errorf("expected exit code %d, got %d (%v)", tt.code, code, err)
+			}
+
+			var archives []installResult
+			if len(archiveJobs) == 1 {
+				var result installResult
+				if err := json.Unmarshal([]byte(out), &result); err != nil {
+					t.Fatalf("invalid document %q: %v", out, err)
+				}
+				if result.Path == nil {
+					t.Error("expected path to be set")
+				}
+				archives = append(archives, result)
+
+				// A single archive fails with its own error
+				var failed *archivesFailed
+				if errors.As(err, &failed) {
+					t.Errorf("expected the archive's error, got %v", err)
+				}
+			} else {
+				var result multiInstallResult
+				if err := json.Unmarshal([]byte(out), &result); err != nil {
+					t.Fatalf("invalid document %q: %v", out, err)
+				}
+				archives = result.Archives
+
+				failed := 0
+				for _, err := range tt.errs {
+					if err != nil {
+						failed++
+					}
+				}
+				if result.Failed != failed {
+					t.Errorf("expected %d failed, got %d", failed, result.Failed)
+				}
+			}
+
+			if len(archives) != len(tt.errs) {
+				t.Fatalf("expected %d archives, got %d", len(tt.errs), len(archives))
+			}
+			for i, err := range tt.errs {
+				expected := ""
+				if err != nil {
+					expected = err.Error()
+				}
+				if archives[i].Error != expected {
+					t.Errorf("archive %d: expected error %q, got %q", i, expected, archives[i].Error)
+				}
+			}
+		})
+	}
+}
+
+// writeArchive writes a tar.gz archive with one executable to a temporary
+// directory
+func writeArchive(t *testing.T) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	content := []byte("#!/bin/sh\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "bin/tool", Mode: 0755, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+	gzw.Close()
+
+	path := filepath.Join(t.TempDir(), "tool.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInstallDocument(t *testing.T) {
+	archivePath := writeArchive(t)
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "bin")
+
+	tests := []struct {
+		name string
+		args []string
+		// hold keeps the lock while bii runs
+		hold bool
+		code int
+		// document is whether a document is written
+		document bool
+	}{
+		{"no match", []string{filepath.Join(dir, "*.zip")}, false, exitNotFound, false},
+		{"not confirmed", []string{archivePath}, false, exitCancelled, false},
+		{"locked", []string{"--yes", "--lock-timeout", "10ms", archivePath}, true, exitLocked, false},
+		{"archive missing", []string{filepath.Join(dir, "missing.zip")}, false, exitNotFound, true},
+		{"installed", []string{"--yes", archivePath}, false, exitOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.hold {
+				path, err := state.LockPath()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				l, err := flock.TryLock(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer l.Release()
+			}
+
+			args := append([]string{"install", "-o", "json", "--skip-path", "--dest", dest}, tt.args...)
+			var code int
+			out := captureStdout(t, func() { code = execute(t, args...) })
+			if code != tt.code {
+				t.Errorf("expected exit code %d, got %d", tt.code, code)
+			}
+
+			if !tt.document {
+				if out != "" {
+					t.Errorf("expected no document, got %q", out)
+				}
+				return
+			}
+			var result installResult
+			if err := json.Unmarshal([]byte(out), &result); err != nil {
+				t.Fatalf("invalid document %q: %v", out, err)
+			}
+			if failed := result.Error != ""; failed != (tt.code != exitOK) {
+				t.Errorf("unexpected error %q", result.Error)
+			}
+		})
+	}
+}
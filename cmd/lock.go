@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/manifest"
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Write bii.lock with the exact archives and binaries of bii.yaml",
+	Long: `Resolve every tool in the manifest to the SHA-256 of its archive and of each
+binary it installs, and write them to bii.lock next to the manifest.
+
+Commit the lockfile; "bii sync --frozen" and "bii install --frozen" then
+refuse anything whose digests differ from it.`,
+	Args: cobra.NoArgs,
+	RunE: runLock,
+}
+
+func init() {
+	lockCmd.Flags().StringVarP(&manifestFile, "file", "f", manifest.FileName, "Manifest to lock")
+}
+
+func runLock(cmd *cobra.Command, args []string) error {
+	m, err := manifest.Load(manifestFile)
+	if err != nil {
+		return err
+	}
+	
+	cache, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	
+	lock := &manifest.Lock{}
+	for _, t := range m.Tools {
+		locked, err := installer.LockTool(m, t, cache, cfg.Trust == "checksum")
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+		lock.Tools = append(lock.Tools, locked)
+		notef("🔒 %s: %s (%d binary(ies))\n", t.Name, locked.SHA256, len(locked.Binaries))
+	}
+	
+	path := manifest.LockPath(manifestFile)
+	if err := lock.Save(path); err != nil {
+		return err
+	}
+	
+	notef("✅ Wrote %s\n", path)
+	return nil
+}
+
+// loadFrozenLock loads the lockfile --frozen checks against
+func loadFrozenLock(path string) (*manifest.Lock, error) {
+	lock, err := manifest.LoadLock(path)
+	if err != nil {
+		return nil, fmt.Errorf("--frozen needs a lockfile: %w", err)
+	}
+	return lock, nil
+}
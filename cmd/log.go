@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	verbose   bool
+	quiet     bool
+	logFormat string
+	// logger writes diagnostics to stderr. Results and what the user asked
+	// for go to stdout with printf and writeResult instead.
+	logger = slog.New(newHumanHandler(os.Stderr, slog.LevelWarn))
+)
+
+// setupLogging checks the logging flags and builds the logger
+func setupLogging() error {
+	level := slog.LevelWarn
+	switch {
+	case verbose && quiet:
+		return usageErrorf("--verbose and --quiet can't be used together")
+	case verbose:
+		level = slog.LevelDebug
+	case quiet:
+		level = slog.LevelError
+	}
+
+	switch logFormat {
+	case "text":
+		logger = slog.New(newHumanHandler(os.Stderr, level))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	default:
+		return usageErrorf("unsupported log format: %s (use text or json)", logFormat)
+	}
+	return nil
+}
+
+// humanHandler writes log records for people: warnings and errors the way
+// bii has always printed them, and debug records with their attributes
+type humanHandler struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	level  slog.Level
+	prefix string
+	attrs  string
+}
+
+func newHumanHandler(out io.Writer, level slog.Level) *humanHandler {
+	return &humanHandler{mu: &sync.Mutex{}, out: out, level: level}
+}
+
+func (h *humanHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *humanHandler) Handle(ctx context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("Error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("⚠️  Warning: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("🔍 ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.writeAttr(&b, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *humanHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	var b strings.Builder
+	for _, a := range attrs {
+		h2.writeAttr(&b, a)
+	}
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *humanHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+// writeAttr writes a as " key=value", quoting values with spaces
+func (h *humanHandler) writeAttr(b *strings.Builder, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		group := h
+		if a.Key != "" {
+			group = &humanHandler{prefix: h.prefix + a.Key + "."}
+		}
+		for _, ga := range a.Value.Group() {
+			group.writeAttr(b, ga)
+		}
+		return
+	}
+
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = fmt.Sprintf("%q", value)
+	}
+	fmt.Fprintf(b, " %s%s=%s", h.prefix, a.Key, value)
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/buildinfo"
+	"github.com/repoleved08/bii/pkg/installer"
+	"gopkg.in/yaml.v3"
+)
+
+// schemaVersion is the version of the structured output documented in
+// OUTPUT.md. It changes only when a field is removed or changes meaning.
+const schemaVersion = 1
+
+var outputFormat string
+
+// entryResult describes an archive entry in structured output
+type entryResult struct {
+	Name   string `json:"name" yaml:"name"`
+	Type   string `json:"type" yaml:"type"`
+	Size   int64  `json:"size" yaml:"size"`
+	Mode   string `json:"mode" yaml:"mode"`
+	OS     string `json:"os,omitempty" yaml:"os,omitempty"`
+	Arch   string `json:"arch,omitempty" yaml:"arch,omitempty"`
+	Binary bool   `json:"binary" yaml:"binary"`
+}
+
+// plannedFileResult describes a file a dry run would install
+type plannedFileResult struct {
+	Source string `json:"source" yaml:"source"`
+	Dest   string `json:"dest" yaml:"dest"`
+	Mode   string `json:"mode" yaml:"mode"`
+	Size   int64  `json:"size" yaml:"size"`
+	Status string `json:"status" yaml:"status"`
+}
+
+// pathResult describes the PATH configuration step
+type pathResult struct {
+	Shell string `json:"shell,omitempty" yaml:"shell,omitempty"`
+	// Status is one of "updated", "already_configured", "planned",
+	// "skipped" or "failed"
+	Status       string   `json:"status" yaml:"status"`
+	ChangedFiles []string `json:"changed_files" yaml:"changed_files"`
+	// Diff holds the changes a dry run would make to the shell config files
+	Diff string `json:"diff,omitempty" yaml:"diff,omitempty"`
+}
+
+// inspectResult is the structured output of inspect
+type inspectResult struct {
+	SchemaVersion int           `json:"schema_version" yaml:"schema_version"`
+	Archive       string        `json:"archive" yaml:"archive"`
+	Format        string        `json:"format" yaml:"format"`
+	Version       string        `json:"version,omitempty" yaml:"version,omitempty"`
+	Entries       []entryResult `json:"entries" yaml:"entries"`
+	Binaries      []string      `json:"binaries" yaml:"binaries"`
+	// Versions are the probed versions of the binaries, by entry name
+	Versions map[string]string `json:"versions,omitempty" yaml:"versions,omitempty"`
+	Warnings []string          `json:"warnings" yaml:"warnings"`
+}
+
+// installResult is the structured output of install
+type installResult struct {
+	SchemaVersion int                 `json:"schema_version" yaml:"schema_version"`
+	Archive       string              `json:"archive" yaml:"archive"`
+	Format        string              `json:"format" yaml:"format"`
+	Version       string              `json:"version,omitempty" yaml:"version,omitempty"`
+	Destination   string              `json:"destination" yaml:"destination"`
+	DryRun        bool                `json:"dry_run" yaml:"dry_run"`
+	Entries       []entryResult       `json:"entries" yaml:"entries"`
+	Binaries      []string            `json:"binaries" yaml:"binaries"`
+	Plan          []plannedFileResult `json:"plan,omitempty" yaml:"plan,omitempty"`
+	Installed     []string            `json:"installed" yaml:"installed"`
+	// Versions are the probed versions of the installed files, by path
+	Versions map[string]string `json:"versions,omitempty" yaml:"versions,omitempty"`
+	// Path is omitted for the archives of a multiInstallResult, which
+	// configures PATH once for all of them
+	Path     *pathResult `json:"path,omitempty" yaml:"path,omitempty"`
+	Warnings []string    `json:"warnings" yaml:"warnings"`
+	// Error is why the archive failed
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// multiInstallResult is the structured output of install with several
+// archives
+type multiInstallResult struct {
+	SchemaVersion int             `json:"schema_version" yaml:"schema_version"`
+	Destination   string          `json:"destination" yaml:"destination"`
+	DryRun        bool            `json:"dry_run" yaml:"dry_run"`
+	Archives      []installResult `json:"archives" yaml:"archives"`
+	Failed        int             `json:"failed" yaml:"failed"`
+	Path          pathResult      `json:"path" yaml:"path"`
+	Warnings      []string        `json:"warnings" yaml:"warnings"`
+}
+
+// toolResult describes what sync did to a tool
+type toolResult struct {
+	Name string `json:"name" yaml:"name"`
+	// Action is one of "install", "upgrade", "remove" or "unchanged"
+	Action string   `json:"action" yaml:"action"`
+	Reason string   `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Dest   string   `json:"dest" yaml:"dest"`
+	Files  []string `json:"files" yaml:"files"`
+}
+
+// syncResult is the structured output of sync
+type syncResult struct {
+	SchemaVersion int          `json:"schema_version" yaml:"schema_version"`
+	Manifest      string       `json:"manifest" yaml:"manifest"`
+	DryRun        bool         `json:"dry_run" yaml:"dry_run"`
+	Tools         []toolResult `json:"tools" yaml:"tools"`
+	Path          []pathResult `json:"path" yaml:"path"`
+	Warnings      []string     `json:"warnings" yaml:"warnings"`
+}
+
+// versionResult is the structured output of version
+type versionResult struct {
+	SchemaVersion  int `json:"schema_version" yaml:"schema_version"`
+	buildinfo.Info `yaml:",inline"`
+}
+
+// validateOutput checks the --output flag
+func validateOutput() error {
+	switch outputFormat {
+	case "text", "json", "yaml":
+		return nil
+	default:
+		return usageErrorf("unsupported output format: %s (use text, json or yaml)", outputFormat)
+	}
+}
+
+// textOutput reports whether human-readable output is enabled
+func textOutput() bool {
+	return outputFormat == "text"
+}
+
+// chatty reports whether human-readable progress and summaries are printed,
+// which --quiet turns off
+func chatty() bool {
+	return textOutput() && !quiet
+}
+
+// printf writes human-readable output, which structured output replaces
+func printf(format string, a ...interface{}) {
+	if chatty() {
+		fmt.Printf(format, a...)
+	}
+}
+
+// printLine is the Println counterpart of printf
+func printLine(a ...interface{}) {
+	if chatty() {
+		fmt.Println(a...)
+	}
+}
+
+// notef prints a confirmation for commands without structured output,
+// unless --quiet is set
+func notef(format string, a ...interface{}) {
+	if !quiet {
+		fmt.Printf(format, a...)
+	}
+}
+
+// warnf logs a warning and returns it for the structured result
+func warnf(format string, a ...interface{}) string {
+	msg := fmt.Sprintf(format, a...)
+	logger.Warn(msg)
+	return msg
+}
+
+// writeResult writes v to stdout in the structured output format
+func writeResult(v interface{}) error {
+	switch outputFormat {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return nil
+	}
+}
+
+func entryResults(entries []archive.Entry) []entryResult {
+	results := []entryResult{}
+	for _, e := range entries {
+		results = append(results, entryResult{
+			Name:   e.Name,
+			Type:   e.Type(),
+			Size:   e.Size,
+			Mode:   fmt.Sprintf("%04o", e.Mode.Perm()),
+			OS:     e.OS,
+			Arch:   e.Arch,
+			Binary: e.IsBinary(),
+		})
+	}
+	return results
+}
+
+func planResults(planned []installer.PlannedFile) []plannedFileResult {
+	results := []plannedFileResult{}
+	for _, p := range planned {
+		results = append(results, plannedFileResult{
+			Source: p.Entry.Name,
+			Dest:   p.Dest,
+			Mode:   fmt.Sprintf("%04o", p.Entry.Mode.Perm()),
+			Size:   p.Entry.Size,
+			Status: string(p.Status),
+		})
+	}
+	return results
+}
+
+func toolResults(actions []installer.Action) []toolResult {
+	results := []toolResult{}
+	for _, a := range actions {
+		files := a.Files
+		if files == nil {
+			files = []string{}
+		}
+		results = append(results, toolResult{
+			Name:   a.Tool,
+			Action: string(a.Kind),
+			Reason: a.Reason,
+			Dest:   a.Dest,
+			Files:  files,
+		})
+	}
+	return results
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/repoleved08/bii/pkg/shell"
+	"github.com/spf13/cobra"
+)
+
+var removeAll bool
+
+var pathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Manage the PATH entries bii adds to your shell configuration",
+}
+
+var pathAddCmd = &cobra.Command{
+	Use:   "add [dir]",
+	Short: "Add a directory to PATH (default: ~/.local/bin)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runPathAdd,
+}
+
+var pathRemoveCmd = &cobra.Command{
+	Use:   "remove [dir]",
+	Short: "Remove a directory bii added to PATH",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runPathRemove,
+}
+
+var pathShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the lines bii manages in your shell configuration",
+	Args:  cobra.NoArgs,
+	RunE:  runPathShow,
+}
+
+func init() {
+	pathRemoveCmd.Flags().BoolVar(&removeAll, "all", false, "Remove everything bii added to the shell configuration")
+
+	pathCmd.AddCommand(pathAddCmd)
+	pathCmd.AddCommand(pathRemoveCmd)
+	pathCmd.AddCommand(pathShowCmd)
+}
+
+func runPathAdd(cmd *cobra.Command, args []string) error {
+	dir, err := pathArg(args)
+	if err != nil {
+		return err
+	}
+
+	release, err := acquireLock(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+	
+	var result pathResult
+	return configurePath(cmd.Context(), dir, &result)
+}
+
+func runPathRemove(cmd *cobra.Command, args []string) error {
+	currentShell, err := detectShell()
+	if err != nil {
+		return err
+	}
+
+	release, err := acquireLock(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+	
+	var changed []string
+	if removeAll {
+		if len(args) != 0 {
+			return usageErrorf("--all does not take a directory")
+		}
+		changed, err = shell.RemoveBlock(currentShell)
+		if err != nil {
+			return err
+		}
+	} else {
+		dir, err := pathArg(args)
+		if err != nil {
+			return err
+		}
+		changed, err = shell.RemoveFromPath(currentShell, dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(changed) == 0 {
+		notef("Nothing to remove\n")
+		return nil
+	}
+
+	notef("✅ Removed bii configuration from:\n")
+	for _, file := range changed {
+		notef("  • %s\n", file)
+	}
+	return nil
+}
+
+func runPathShow(cmd *cobra.Command, args []string) error {
+	currentShell, err := detectShell()
+	if err != nil {
+		return err
+	}
+
+	managed, err := shell.ManagedLines(currentShell)
+	if err != nil {
+		return err
+	}
+
+	if len(managed) == 0 {
+		fmt.Printf("bii manages nothing in the %s configuration\n", currentShell)
+		return nil
+	}
+
+	files := make([]string, 0, len(managed))
+	for file := range managed {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		fmt.Printf("📝 %s:\n", file)
+		for _, line := range managed[file] {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	return nil
+}
+
+// pathArg returns the absolute directory given in args, or the default destination
+func pathArg(args []string) (string, error) {
+	if len(args) == 0 {
+		return defaultDestDir()
+	}
+	return filepath.Abs(args[0])
+}
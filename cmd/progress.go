@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/repoleved08/bii/pkg/progress"
+	"golang.org/x/term"
+)
+
+// progressInterval is how often the progress bar is redrawn at most
+const progressInterval = 100 * time.Millisecond
+
+// progressWidth is the number of cells in the progress bar
+const progressWidth = 24
+
+// progressRenderer shows progress events on stderr, keeping stdout for
+// results: a bar redrawn in place on a terminal, or a line per step
+// otherwise. Installed binaries and PATH changes are left to the summary.
+type progressRenderer struct {
+	mu  sync.Mutex
+	out io.Writer
+	tty bool
+	// multi prefixes entries with their archive, for several archives
+	multi bool
+	drawn bool
+	last  time.Time
+}
+
+// newProgressRenderer returns a renderer for text output, or nil when
+// structured output or --quiet is enabled
+func newProgressRenderer(multi bool) *progressRenderer {
+	if !chatty() {
+		return nil
+	}
+	return &progressRenderer{
+		out:   os.Stderr,
+		tty:   term.IsTerminal(int(os.Stderr.Fd())),
+		multi: multi,
+	}
+}
+
+// Func returns the callback that renders events
+func (r *progressRenderer) Func() progress.Func {
+	if r == nil {
+		return nil
+	}
+	return r.handle
+}
+
+// Finish clears the progress bar, if one is drawn
+func (r *progressRenderer) Finish() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clear()
+}
+
+func (r *progressRenderer) handle(e progress.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	label := path.Base(e.Entry)
+	if r.multi && e.Archive != "" {
+		label = e.Archive + ": " + label
+	}
+
+	if !r.tty {
+		switch e.Kind {
+		case progress.Downloading:
+			fmt.Fprintf(r.out, "Downloading %s (%s)\n", e.Entry, formatBytes(e.Total))
+		case progress.EntryStarted:
+			fmt.Fprintf(r.out, "Extracting %s (%s)\n", label, formatBytes(e.Total))
+		case progress.Verified:
+			fmt.Fprintf(r.out, "Verified %s against the lockfile\n", e.Archive)
+		}
+		return
+	}
+
+	switch e.Kind {
+	case progress.Downloading, progress.EntryStarted:
+		r.draw(label, 0, e.Total)
+	case progress.BytesWritten:
+		if time.Since(r.last) >= progressInterval {
+			r.draw(label, e.Bytes, e.Total)
+		}
+	case progress.EntryDone:
+		r.clear()
+	}
+}
+
+// draw redraws the progress bar for an entry
+func (r *progressRenderer) draw(label string, written, total int64) {
+	r.last = time.Now()
+	r.drawn = true
+
+	if total <= 0 {
+		fmt.Fprintf(r.out, "\r\033[K  %s %s", label, formatBytes(written))
+		return
+	}
+
+	filled := int(written * progressWidth / total)
+	if filled > progressWidth {
+		filled = progressWidth
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressWidth-filled)
+	fmt.Fprintf(r.out, "\r\033[K  %s %s %3d%% %s/%s", bar, label, written*100/total, formatBytes(written), formatBytes(total))
+}
+
+// clear removes the progress bar from the terminal
+func (r *progressRenderer) clear() {
+	if r.drawn {
+		fmt.Fprint(r.out, "\r\033[K")
+		r.drawn = false
+	}
+}
+
+// formatBytes formats a size for humans, e.g. "12.3 MB"
+func formatBytes(n int64) string {
+	if n < 0 {
+		return "unknown size"
+	}
+
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
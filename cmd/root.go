@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/repoleved08/bii/pkg/archive"
-	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/bii"
+	"github.com/repoleved08/bii/pkg/prompt"
 	"github.com/repoleved08/bii/pkg/shell"
 	"github.com/spf13/cobra"
 )
@@ -15,177 +23,291 @@ var (
 	destDir    string
 	skipPath   bool
 	forceYes   bool
+	dryRun     bool
+	shellName  string
+	frozen     bool
+	system     bool
+	lockWait   time.Duration
+	lockFile   string
+	// started is set once the command line was parsed and a command runs
+	started    bool
 	rootCmd    = &cobra.Command{
 		Use:   "bii",
 		Short: "Binary Installation Interface - Install binaries from archives",
 		Long:  `bii helps you install binary tools from ZIP and TAR archives with automatic PATH management.`,
+		
+		// Execute reports errors, and usage only helps with usage errors
+		SilenceErrors: true,
+		
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			started = true
+			cmd.SilenceUsage = true
+			
+			if err := setupLogging(); err != nil {
+				return err
+			}
+			
+			// config set reads the file itself, so a bad BII_* variable doesn't
+			// keep it from working
+			if cmd != configSetCmd {
+				if err := loadConfig(cmd); err != nil {
+					return err
+				}
+			}
+			return validateOutput()
+		},
 	}
 )
 
 func init() {
-	installCmd.Flags().StringVarP(&destDir, "dest", "d", "", "Destination directory (default: ~/.local/bin)")
-	installCmd.Flags().BoolVarP(&skipPath, "skip-path", "s", false, "Skip PATH configuration")
-	installCmd.Flags().BoolVarP(&forceYes, "yes", "y", false, "Skip confirmation prompts")
+	rootCmd.PersistentFlags().StringVar(&shellName, "shell", "", "Shell to configure (default: detected from the parent process)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", "Output format for inspect, install, sync and version: text, json or yaml")
+	rootCmd.PersistentFlags().DurationVar(&lockWait, "lock-timeout", time.Minute, "How long to wait for another bii process to finish")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log what bii decides and does to stderr")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print errors, results and questions")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Format of the log on stderr: text or json")
+	rootCmd.RegisterFlagCompletionFunc("shell", completeShells)
+	rootCmd.RegisterFlagCompletionFunc("output", completeOutputFormats)
+	rootCmd.RegisterFlagCompletionFunc("log-format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	
+	inspectCmd.Flags().BoolVar(&probeVersion, probeVersionFlag, false, probeVersionUsage)
 	
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(inspectCmd)
 	rootCmd.AddCommand(versionCmd)
-}
-
-var installCmd = &cobra.Command{
-	Use:   "install <archive>",
-	Short: "Install binaries from an archive",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runInstall,
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(pathCmd)
+	rootCmd.AddCommand(shellInitCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(configCmd)
 }
 
 var inspectCmd = &cobra.Command{
 	Use:   "inspect <archive>",
 	Short: "Inspect an archive and show detected binaries",
+	Long: `Inspect an archive and show detected binaries.
+
+Pass "-" to read the archive from stdin.`,
 	Args:  cobra.ExactArgs(1),
 	RunE:  runInspect,
-}
-
-var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Show version information",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("bii v0.1.0")
-		fmt.Println("Author: Norman Bii <geekbii08@gmail.com>")
-	},
+	
+	ValidArgsFunction: completeArchives,
 }
 
 func runInspect(cmd *cobra.Command, args []string) error {
 	archivePath := args[0]
 	
-	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
-		return fmt.Errorf("archive not found: %s", archivePath)
+	if archivePath == stdinArchive {
+		printf("📦 Inspecting archive from stdin\n\n")
+	} else {
+		printf("📦 Inspecting: %s\n\n", archivePath)
 	}
 	
-	fmt.Printf("📦 Inspecting: %s\n\n", archivePath)
+	opts := []bii.Option{bii.WithShell(shellName), bii.WithLogger(logger)}
+	if probeVersion {
+		opts = append(opts, bii.WithVersionProbe(0))
+	}
+	inst, err := bii.New(opts...)
+	if err != nil {
+		return err
+	}
 	
-	binaries, err := archive.DetectBinaries(archivePath)
+	var inspection *bii.Inspection
+	if archivePath == stdinArchive {
+		inspection, err = inst.InspectReader(cmd.Context(), archivePath, os.Stdin)
+	} else {
+		inspection, err = inst.Inspect(cmd.Context(), archivePath)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to inspect archive: %w", err)
+		return err
+	}
+	
+	result := inspectResult{
+		SchemaVersion: schemaVersion,
+		Archive:       archivePath,
+		Format:        inspection.Format,
+		Version:       inspection.Version,
+		Entries:       entryResults(inspection.Entries),
+		Binaries:      []string{},
+		Versions:      inspection.Versions,
+		Warnings:      []string{},
+	}
+	
+	binaries := inspection.Binaries
+	for _, bin := range binaries {
+		result.Binaries = append(result.Binaries, bin.Name)
 	}
 	
 	if len(binaries) == 0 {
-		fmt.Println("❌ No executable binaries detected in archive")
-		return nil
+		printLine("❌ No executable binaries detected in archive")
+		return writeResult(result)
 	}
 	
-	fmt.Printf("✅ Found %d executable(s):\n", len(binaries))
+	printf("✅ Found %d executable(s):\n", len(binaries))
+	if inspection.Version != "" {
+		printf("📌 Version %s, from the file name\n", inspection.Version)
+	}
 	for _, bin := range binaries {
-		fmt.Printf("  • %s\n", bin)
+		printf("  • %s%s%s\n", bin.Name, platformSuffix(bin), versionSuffix(inspection.Versions[bin.Name]))
 	}
 	
-	return nil
+	return writeResult(result)
 }
 
-func runInstall(cmd *cobra.Command, args []string) error {
-	archivePath := args[0]
-	
-	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
-		return fmt.Errorf("archive not found: %s", archivePath)
+// versionSuffix formats a probed version for a list of binaries
+func versionSuffix(v string) string {
+	if v == "" {
+		return ""
 	}
-	
-	// Set default destination
-	if destDir == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
+	return " v" + v
+}
+
+// platformSuffix describes the platform of a binary for text output
+func platformSuffix(e archive.Entry) string {
+	if e.OS == "" {
+		if e.Arch == "" {
+			return ""
 		}
-		destDir = filepath.Join(home, ".local", "bin")
-	}
-	
-	// Ensure destination exists
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+		return fmt.Sprintf(" (%s)", e.Arch)
 	}
-	
-	fmt.Printf("📦 Installing from: %s\n", archivePath)
-	fmt.Printf("📁 Destination: %s\n\n", destDir)
-	
-	// Detect binaries
-	binaries, err := archive.DetectBinaries(archivePath)
-	if err != nil {
-		return fmt.Errorf("failed to inspect archive: %w", err)
+	if e.Arch == "" {
+		return fmt.Sprintf(" (%s)", e.OS)
 	}
-	
-	if len(binaries) == 0 {
-		return fmt.Errorf("no executable binaries found in archive")
+	return fmt.Sprintf(" (%s/%s)", e.OS, e.Arch)
+}
+
+// newPrompter returns the Prompter for confirmations. --yes and
+// BII_ASSUME_YES skip them; otherwise they need stdin to be a terminal.
+func newPrompter() prompt.Prompter {
+	if forceYes || prompt.EnvAssumeYes() {
+		return prompt.AssumeYes{}
 	}
-	
-	fmt.Printf("✅ Found %d executable(s):\n", len(binaries))
-	for _, bin := range binaries {
-		fmt.Printf("  • %s\n", bin)
-	}
-	fmt.Println()
-	
-	// Confirm installation
-	if !forceYes {
-		fmt.Print("Continue with installation? [Y/n]: ")
-		var response string
-		fmt.Scanln(&response)
-		if response != "" && response != "Y" && response != "y" {
-			fmt.Println("Installation cancelled")
-			return nil
-		}
+	return prompt.Stdio(promptOutput())
+}
+
+// promptOutput returns where questions are written, keeping stdout free for
+// structured output
+func promptOutput() io.Writer {
+	if textOutput() {
+		return os.Stdout
 	}
-	
-	// Install binaries
-	installed, err := installer.Install(archivePath, destDir, binaries)
+	return os.Stderr
+}
+
+// acquireLock keeps other bii processes from changing the destination,
+// shell configuration and state until release is called
+func acquireLock(ctx context.Context) (release func(), err error) {
+	inst, err := bii.New(bii.WithLogger(logger), bii.WithLockTimeout(lockWait))
 	if err != nil {
-		return fmt.Errorf("installation failed: %w", err)
+		return nil, err
 	}
-	
-	fmt.Printf("\n✅ Successfully installed %d binary(ies) to %s\n", len(installed), destDir)
-	for _, bin := range installed {
-		fmt.Printf("  • %s\n", filepath.Base(bin))
+	return inst.Lock(ctx)
+}
+
+// detectShell returns the shell given with --shell, or the detected one
+func detectShell() (string, error) {
+	if shellName != "" {
+		return shellName, nil
 	}
-	
-	// Handle PATH configuration
-	if !skipPath {
-		fmt.Println()
-		if err := configurePath(destDir); err != nil {
-			fmt.Fprintf(os.Stderr, "⚠️  Warning: PATH configuration failed: %v\n", err)
-			fmt.Printf("Please manually add to PATH: export PATH=\"%s:$PATH\"\n", destDir)
-		}
+	return shell.DetectShell()
+}
+
+// defaultDestDir returns the directory binaries are installed to by default,
+// which the config can change
+func defaultDestDir() (string, error) {
+	if cfg.Dest != "" {
+		return expandPath(cfg.Dest)
 	}
 	
-	return nil
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "bin"), nil
 }
 
-func configurePath(dir string) error {
-	currentShell, err := shell.DetectShell()
+// configurePath adds dir to PATH in the shell configuration and records the
+// outcome in result
+func configurePath(ctx context.Context, dir string, result *pathResult) error {
+	inst, err := bii.New(bii.WithDest(dir), bii.WithShell(shellName), bii.WithLogger(logger), bii.WithLockTimeout(lockWait))
 	if err != nil {
 		return err
 	}
+	return applyPath(ctx, inst, result)
+}
+
+// applyPath adds the destination of inst to PATH, printing and recording
+// the outcome in result
+func applyPath(ctx context.Context, inst *bii.Installer, result *pathResult) error {
+	dir := inst.Dest()
 	
-	fmt.Printf("🐚 Detected shell: %s\n", currentShell)
-	
-	inPath, err := shell.IsInPath(dir)
+	configured, err := inst.ConfigurePath(ctx)
+	if configured != nil {
+		result.Shell = configured.Shell
+		if configured.Shell != "" {
+			if shellName != "" {
+				printf("🐚 Shell: %s\n", configured.Shell)
+			} else {
+				printf("🐚 Detected shell: %s\n", configured.Shell)
+			}
+		}
+	}
 	if err != nil {
 		return err
 	}
 	
-	if inPath {
-		fmt.Printf("✅ %s is already in PATH\n", dir)
+	result.Status = string(configured.Status)
+	if configured.Status == bii.PathAlreadyConfigured {
+		printf("✅ %s is already in PATH\n", dir)
 		return nil
 	}
 	
-	fmt.Printf("📝 Adding %s to PATH...\n", dir)
+	if configured.InCurrentPath {
+		printf("ℹ️  %s is in the current PATH but not set up in your shell configuration\n", dir)
+	}
 	
-	if err := shell.AddToPath(currentShell, dir); err != nil {
-		return err
+	result.ChangedFiles = append(result.ChangedFiles, configured.ChangedFiles...)
+	if configured.Shell == "" {
+		printf("✅ PATH updated in %s\n", strings.Join(configured.ChangedFiles, ", "))
+		printLine("💡 Log in again to pick up the change")
+		return nil
 	}
 	
-	fmt.Printf("✅ PATH updated in shell configuration\n")
-	fmt.Println("💡 Restart your shell or run: source ~/.<shell>rc")
+	printf("✅ PATH updated in shell configuration:\n")
+	for _, file := range configured.ChangedFiles {
+		printf("  • %s\n", file)
+	}
+	printLine("💡 Restart your shell or log in again to pick up the change")
 	
 	return nil
 }
 
-func Execute() error {
-	return rootCmd.Execute()
+// Execute runs bii, reports any error on stderr and returns the exit code.
+// An interrupt cancels the command's context, so it can clean up and fail
+// with exitCancelled; a second one kills bii.
+func Execute() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	
+	err := rootCmd.ExecuteContext(ctx)
+	if err == nil {
+		return exitOK
+	}
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		err = fmt.Errorf("interrupted: %w", err)
+	}
+	
+	// Cobra fails on unknown commands, flags and arguments before running
+	// anything
+	if !started {
+		err = usageError{err}
+	}
+	logger.Error(err.Error())
+	return exitCode(err)
 }
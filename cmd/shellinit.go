@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/repoleved08/bii/pkg/shell"
+	"github.com/spf13/cobra"
+)
+
+var shellInitDest string
+
+var shellInitCmd = &cobra.Command{
+	Use:     "shell-init [shell]",
+	Aliases: []string{"env"},
+	Short:   "Print shell code that sets up PATH, MANPATH and completion",
+	Long: `Print shell code that sets up PATH, MANPATH and completion for bii's
+install directory, as an alternative to letting bii edit your shell config.
+
+Add one of these to your shell configuration:
+  bash/zsh/sh: eval "$(bii shell-init bash)"
+  fish:        bii shell-init fish | source
+  pwsh:        bii shell-init pwsh | Out-String | Invoke-Expression
+
+For shells that can't evaluate command output, save the script to a file
+and source that instead. The shell defaults to the one bii detects.`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: supportedShells,
+	RunE:      runShellInit,
+}
+
+func init() {
+	shellInitCmd.Flags().StringVarP(&shellInitDest, "dest", "d", "", "Install directory to set up (default: ~/.local/bin)")
+}
+
+func runShellInit(cmd *cobra.Command, args []string) error {
+	sh, err := detectShell()
+	if len(args) == 1 {
+		sh, err = args[0], nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dir := shellInitDest
+	if dir == "" {
+		d, err := defaultDestDir()
+		if err != nil {
+			return err
+		}
+		dir = d
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	script, err := shell.InitScript(sh, shell.InitOptions{
+		BinDirs: []string{dir},
+		// Man pages live next to bin, e.g. ~/.local/share/man
+		ManDirs:    []string{filepath.Join(filepath.Dir(dir), "share", "man")},
+		Completion: "bii completion " + sh,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(cmd.OutOrStdout(), script)
+	return err
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/manifest"
+	"github.com/repoleved08/bii/pkg/prompt"
+	"github.com/repoleved08/bii/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	manifestFile string
+	prune        bool
+	syncDest     string
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Install, upgrade and remove tools to match bii.yaml",
+	Long: `Make the installed tools match the manifest (bii.yaml by default).
+
+Tools that are missing are installed, tools whose manifest entry changed are
+upgraded, and with --prune tools bii installed that are no longer listed are
+removed, keeping any file a listed tool now installs. Two tools can't install
+the same file, and existing files bii didn't install are handled according to
+the conflict setting (bii config set conflict). Installed tools are recorded in
+$XDG_STATE_HOME/bii/state.json.`,
+	Args: cobra.NoArgs,
+	RunE: runSync,
+}
+
+func init() {
+	syncCmd.Flags().StringVarP(&manifestFile, "file", "f", manifest.FileName, "Manifest to sync")
+	syncCmd.Flags().BoolVar(&prune, "prune", false, "Remove tools installed by bii that the manifest no longer lists")
+	syncCmd.Flags().StringVarP(&syncDest, "dest", "d", "", "Destination for tools without one in the manifest (default: ~/.local/bin)")
+	syncCmd.Flags().BoolVarP(&skipPath, "skip-path", "s", false, "Skip PATH configuration")
+	syncCmd.Flags().BoolVarP(&forceYes, "yes", "y", false, "Skip confirmation prompts (also set by BII_ASSUME_YES=1)")
+	syncCmd.Flags().BoolVar(&frozen, "frozen", false, "Refuse archives and binaries whose digests differ from bii.lock")
+	syncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would change without touching the filesystem")
+	syncCmd.RegisterFlagCompletionFunc("file", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	m, err := manifest.Load(manifestFile)
+	if err != nil {
+		return err
+	}
+	
+	dest := syncDest
+	if dest == "" {
+		dir, err := defaultDestDir()
+		if err != nil {
+			return err
+		}
+		dest = dir
+	}
+	
+	// Planning reads the state, so the lock is held from here on
+	if !dryRun {
+		release, err := acquireLock(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	
+	statePath, err := state.DefaultPath()
+	if err != nil {
+		return err
+	}
+	s, err := state.Load(statePath)
+	if err != nil {
+		return err
+	}
+	
+	cache, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	
+	policy, err := installer.ParseConflictPolicy(conflictPolicy)
+	if err != nil {
+		return err
+	}
+	
+	opts := installer.SyncOptions{
+		Conflict:        policy,
+		DefaultDest:     dest,
+		Prune:           prune,
+		CacheDir:        cache,
+		RequireChecksum: cfg.Trust == "checksum",
+		Logger:          logger,
+	}
+	bar := newProgressRenderer(true)
+	opts.Progress = bar.Func()
+	if frozen {
+		if opts.Lock, err = loadFrozenLock(manifest.LockPath(manifestFile)); err != nil {
+			return err
+		}
+	}
+	actions := installer.PlanSync(m, s, opts)
+	
+	result := syncResult{
+		SchemaVersion: schemaVersion,
+		Manifest:      manifestFile,
+		DryRun:        dryRun,
+		Tools:         []toolResult{},
+		Path:          []pathResult{},
+		Warnings:      []string{},
+	}
+	
+	pending := 0
+	printf("📋 Syncing %s\n\n", manifestFile)
+	for _, a := range actions {
+		if a.Kind != installer.ActionUnchanged {
+			pending++
+		}
+		printf("  %-9s %s%s\n", a.Kind, a.Tool, actionReason(a))
+	}
+	printLine()
+	
+	if pending == 0 {
+		printLine("✅ Everything is up to date")
+		result.Tools = toolResults(actions)
+		return writeResult(result)
+	}
+	
+	if dryRun {
+		printLine("📋 Dry run, nothing will be changed")
+		result.Tools = toolResults(actions)
+		return writeResult(result)
+	}
+	
+	ok, err := prompt.ConfirmContext(cmd.Context(), newPrompter(), fmt.Sprintf("Apply %d change(s)?", pending), true)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("sync %w", prompt.ErrDeclined)
+	}
+	
+	done, syncErr := installer.Sync(m, s, actions, opts)
+	bar.Finish()
+	
+	// Record whatever was synced, even if a later tool failed
+	if err := s.Save(statePath); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if syncErr != nil {
+		return fmt.Errorf("sync failed: %w", syncErr)
+	}
+	result.Tools = toolResults(done)
+	
+	for _, a := range done {
+		switch a.Kind {
+		case installer.ActionInstall, installer.ActionUpgrade:
+			printf("✅ %s: installed %d file(s) to %s\n", a.Tool, len(a.Files), a.Dest)
+		case installer.ActionRemove:
+			printf("🗑️  %s: removed %d file(s)\n", a.Tool, len(a.Files))
+		}
+	}
+	
+	if skipPath {
+		return writeResult(result)
+	}
+	
+	// Configure PATH once for every directory tools were installed to
+	seen := make(map[string]bool)
+	for _, a := range done {
+		if a.Kind == installer.ActionRemove || seen[a.Dest] {
+			continue
+		}
+		seen[a.Dest] = true
+		
+		printLine()
+		path := pathResult{Status: "skipped", ChangedFiles: []string{}}
+		if err := configurePath(cmd.Context(), a.Dest, &path); err != nil {
+			path.Status = "failed"
+			result.Warnings = append(result.Warnings, warnf("PATH configuration failed for %s: %v", a.Dest, err))
+		}
+		result.Path = append(result.Path, path)
+	}
+	
+	return writeResult(result)
+}
+
+// actionReason formats why a tool changes for text output
+func actionReason(a installer.Action) string {
+	if a.Reason == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", a.Reason)
+}
+
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/repoleved08/bii/pkg/buildinfo"
+	"github.com/spf13/cobra"
+)
+
+// versionJSON is the --json flag of version
+var versionJSON bool
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show version information",
+	Long: `Show the version of bii, the commit and date it was built from, and the Go
+version and platform it was built with.
+
+Use --json (or -o json / -o yaml) for inventory scripts.`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print the version information as JSON (same as -o json)")
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	if versionJSON {
+		outputFormat = "json"
+	}
+	info := buildinfo.Get()
+
+	if !textOutput() {
+		return writeResult(versionResult{SchemaVersion: schemaVersion, Info: info})
+	}
+
+	fmt.Printf("bii %s\n", info.Version)
+	if info.Commit != "" {
+		dirty := ""
+		if info.Dirty {
+			dirty = " (modified)"
+		}
+		fmt.Printf("Commit: %s%s\n", info.ShortCommit(), dirty)
+	}
+	if info.Date != "" {
+		fmt.Printf("Built:  %s\n", info.Date)
+	}
+	fmt.Printf("Go:     %s %s\n", info.GoVersion, info.Platform)
+	fmt.Println("Author: Norman Bii <geekbii08@gmail.com>")
+	return nil
+}
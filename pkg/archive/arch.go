@@ -0,0 +1,203 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// headerSize is how much of an executable is read to detect its platform
+const headerSize = 4096
+
+var (
+	elfMachines = map[uint16]string{
+		3:   "386",
+		8:   "mips",
+		20:  "ppc",
+		21:  "ppc64",
+		22:  "s390x",
+		40:  "arm",
+		62:  "amd64",
+		183: "arm64",
+		243: "riscv64",
+		258: "loong64",
+	}
+	machoCPUs = map[uint32]string{
+		7:          "386",
+		0x01000007: "amd64",
+		12:         "arm",
+		0x0100000c: "arm64",
+	}
+	// elfOSABIs maps EI_OSABI to an operating system. Linux binaries
+	// mostly leave it 0, "System V", like those of several other systems.
+	elfOSABIs = map[byte]string{
+		2:  "netbsd",
+		3:  "linux",
+		6:  "solaris",
+		9:  "freebsd",
+		12: "openbsd",
+	}
+	// elfNoteOwners maps the owner of an ELF note that identifies an
+	// operating system to it
+	elfNoteOwners = map[string]string{
+		"FreeBSD":   "freebsd",
+		"NetBSD":    "netbsd",
+		"OpenBSD":   "openbsd",
+		"DragonFly": "dragonfly",
+		"Android":   "android",
+	}
+	peMachines = map[uint16]string{
+		0x14c:  "386",
+		0x8664: "amd64",
+		0x1c0:  "arm",
+		0x1c4:  "arm",
+		0xaa64: "arm64",
+	}
+)
+
+// readHeader reads the start of an executable for detectPlatform
+func readHeader(r io.Reader) []byte {
+	buf := make([]byte, headerSize)
+	n, _ := io.ReadFull(r, buf)
+	return buf[:n]
+}
+
+// detectPlatform returns the operating system and architecture an
+// executable was built for, judging by its ELF, Mach-O or PE header. Both
+// are empty if the format isn't recognised, e.g. for scripts, and the
+// operating system is empty if the header doesn't tell.
+func detectPlatform(header []byte) (goos, goarch string) {
+	switch {
+	case bytes.HasPrefix(header, []byte("\x7fELF")) && len(header) >= 20:
+		var order binary.ByteOrder = binary.LittleEndian
+		if header[5] == 2 {
+			order = binary.BigEndian
+		}
+		return elfOS(header, order), elfMachines[order.Uint16(header[18:20])]
+		
+	case len(header) >= 8 && (bytes.HasPrefix(header, []byte{0xce, 0xfa, 0xed, 0xfe}) || bytes.HasPrefix(header, []byte{0xcf, 0xfa, 0xed, 0xfe})):
+		return "darwin", machoCPUs[binary.LittleEndian.Uint32(header[4:8])]
+		
+	case len(header) >= 8 && bytes.HasPrefix(header, []byte{0xca, 0xfe, 0xba, 0xbe}):
+		// Java class files share this magic but have a version number well
+		// above any realistic count of architectures
+		if binary.BigEndian.Uint32(header[4:8]) < 20 {
+			return "darwin", "universal"
+		}
+		
+	case bytes.HasPrefix(header, []byte("MZ")) && len(header) >= 0x40:
+		offset := int(binary.LittleEndian.Uint32(header[0x3c:0x40]))
+		if offset+6 <= len(header) && bytes.Equal(header[offset:offset+4], []byte("PE\x00\x00")) {
+			return "windows", peMachines[binary.LittleEndian.Uint16(header[offset+4 : offset+6])]
+		}
+	}
+	
+	return "", ""
+}
+
+// Program header types elfOS looks at
+const (
+	ptInterp = 3
+	ptNote   = 4
+)
+
+// elfOS returns the operating system of an ELF executable, from EI_OSABI
+// or, when that is left 0, from the program interpreter and the notes
+// that identify the system. It returns "" if none of them are in header,
+// such as for static Linux binaries.
+func elfOS(header []byte, order binary.ByteOrder) string {
+	if goos, ok := elfOSABIs[header[7]]; ok {
+		return goos
+	}
+	if header[7] != 0 {
+		return ""
+	}
+	
+	// Find the program headers, which usually follow the ELF header
+	var phoff, phentsize, phnum int
+	is64 := header[4] == 2
+	switch {
+	case is64 && len(header) >= 64:
+		phoff = int(order.Uint64(header[32:40]))
+		phentsize = int(order.Uint16(header[54:56]))
+		phnum = int(order.Uint16(header[56:58]))
+		if phentsize < 56 {
+			return ""
+		}
+	case !is64 && len(header) >= 52:
+		phoff = int(order.Uint32(header[28:32]))
+		phentsize = int(order.Uint16(header[42:44]))
+		phnum = int(order.Uint16(header[44:46]))
+		if phentsize < 32 {
+			return ""
+		}
+	default:
+		return ""
+	}
+	
+	goos := ""
+	for n := 0; n < phnum; n++ {
+		ph := phoff + n*phentsize
+		if phoff <= 0 || ph < 0 || ph+phentsize > len(header) {
+			break
+		}
+		
+		var off, size int
+		if is64 {
+			off, size = int(order.Uint64(header[ph+8:ph+16])), int(order.Uint64(header[ph+32:ph+40]))
+		} else {
+			off, size = int(order.Uint32(header[ph+4:ph+8])), int(order.Uint32(header[ph+16:ph+20]))
+		}
+		if off < 0 || size < 0 || off+size > len(header) || off+size < off {
+			continue
+		}
+		segment := header[off : off+size]
+		
+		switch order.Uint32(header[ph : ph+4]) {
+		case ptInterp:
+			interp := string(bytes.TrimRight(segment, "\x00"))
+			if strings.Contains(interp, "/ld-linux") || strings.Contains(interp, "/ld-musl-") {
+				goos = "linux"
+			}
+		case ptNote:
+			if noteOS := elfNoteOS(segment, order); noteOS != "" {
+				return noteOS
+			}
+		}
+	}
+	
+	return goos
+}
+
+// elfNoteOS returns the operating system named by the notes in an ELF
+// PT_NOTE segment, or ""
+func elfNoteOS(notes []byte, order binary.ByteOrder) string {
+	align4 := func(n int) int { return (n + 3) &^ 3 }
+	
+	for len(notes) >= 12 {
+		namesz := int(order.Uint32(notes[0:4]))
+		descsz := int(order.Uint32(notes[4:8]))
+		typ := order.Uint32(notes[8:12])
+		if namesz < 0 || descsz < 0 || 12+align4(namesz) < 12 || 12+align4(namesz)+descsz > len(notes) {
+			return ""
+		}
+		name := string(bytes.TrimRight(notes[12:12+namesz], "\x00"))
+		desc := notes[12+align4(namesz) : 12+align4(namesz)+descsz]
+		
+		if goos, ok := elfNoteOwners[name]; ok {
+			return goos
+		}
+		// NT_GNU_ABI_TAG, whose first word is the kernel: 0 for Linux
+		if name == "GNU" && typ == 1 && len(desc) >= 4 && order.Uint32(desc[0:4]) == 0 {
+			return "linux"
+		}
+		
+		next := 12 + align4(namesz) + align4(descsz)
+		if next > len(notes) {
+			return ""
+		}
+		notes = notes[next:]
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"encoding/binary"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	elf := func(data, osabi byte, machine uint16) []byte {
+		h := make([]byte, 64)
+		copy(h, "\x7fELF")
+		h[4] = 2
+		h[5] = data
+		h[7] = osabi
+		if data == 2 {
+			binary.BigEndian.PutUint16(h[18:], machine)
+		} else {
+			binary.LittleEndian.PutUint16(h[18:], machine)
+		}
+		return h
+	}
+
+	// elfSegment is a little-endian amd64 ELF with a single program
+	// header of type typ, whose content is data
+	elfSegment := func(typ uint32, data []byte) []byte {
+		h := elf(1, 0, 62)
+		binary.LittleEndian.PutUint64(h[32:], 64)
+		binary.LittleEndian.PutUint16(h[54:], 56)
+		binary.LittleEndian.PutUint16(h[56:], 1)
+
+		ph := make([]byte, 56)
+		binary.LittleEndian.PutUint32(ph[0:], typ)
+		binary.LittleEndian.PutUint64(ph[8:], 120)
+		binary.LittleEndian.PutUint64(ph[32:], uint64(len(data)))
+		return append(append(h, ph...), data...)
+	}
+
+	note := func(owner string, typ uint32, desc []byte) []byte {
+		name := []byte(owner + "\x00")
+		for len(name)%4 != 0 {
+			name = append(name, 0)
+		}
+		n := make([]byte, 12)
+		binary.LittleEndian.PutUint32(n[0:], uint32(len(owner)+1))
+		binary.LittleEndian.PutUint32(n[4:], uint32(len(desc)))
+		binary.LittleEndian.PutUint32(n[8:], typ)
+		return append(append(n, name...), desc...)
+	}
+
+	machO := func(cpu uint32) []byte {
+		h := []byte{0xcf, 0xfa, 0xed, 0xfe, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(h[4:], cpu)
+		return h
+	}
+
+	pe := func(machine uint16) []byte {
+		h := make([]byte, 0x100)
+		copy(h, "MZ")
+		binary.LittleEndian.PutUint32(h[0x3c:], 0x80)
+		copy(h[0x80:], "PE\x00\x00")
+		binary.LittleEndian.PutUint16(h[0x84:], machine)
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		header []byte
+		os     string
+		arch   string
+	}{
+		{"ELF GNU/Linux amd64", elf(1, 3, 62), "linux", "amd64"},
+		{"ELF FreeBSD arm64", elf(1, 9, 183), "freebsd", "arm64"},
+		{"ELF big-endian s390x", elf(2, 3, 22), "linux", "s390x"},
+		{"ELF System V without clues", elf(1, 0, 62), "", "amd64"},
+		{"ELF unknown OS/ABI", elf(1, 97, 40), "", "arm"},
+		{"ELF Linux interpreter", elfSegment(3, []byte("/lib64/ld-linux-x86-64.so.2\x00")), "linux", "amd64"},
+		{"ELF musl interpreter", elfSegment(3, []byte("/lib/ld-musl-x86_64.so.1\x00")), "linux", "amd64"},
+		{"ELF GNU ABI tag", elfSegment(4, note("GNU", 1, []byte{0, 0, 0, 0, 3, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0})), "linux", "amd64"},
+		{"ELF OpenBSD note", elfSegment(4, append(note("Go", 4, []byte("abcd")), note("OpenBSD", 1, []byte{0, 0, 0, 0})...)), "openbsd", "amd64"},
+		{"ELF Go build ID only", elfSegment(4, note("Go", 4, []byte("abcd"))), "", "amd64"},
+		{"ELF truncated note", elfSegment(4, note("NetBSD", 1, []byte{0, 0, 0, 0})[:14]), "", "amd64"},
+		{"Mach-O arm64", machO(0x0100000c), "darwin", "arm64"},
+		{"Mach-O amd64", machO(0x01000007), "darwin", "amd64"},
+		{"Mach-O universal", []byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 2}, "darwin", "universal"},
+		{"Java class", []byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 61}, "", ""},
+		{"PE amd64", pe(0x8664), "windows", "amd64"},
+		{"PE arm64", pe(0xaa64), "windows", "arm64"},
+		{"Script", []byte("#!/bin/sh\necho hi\n"), "", ""},
+		{"Truncated", []byte("\x7fEL"), "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goos, goarch := detectPlatform(tt.header)
+			if goos != tt.os || goarch != tt.arch {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.os, tt.arch, goos, goarch)
+			}
+		})
+	}
+}
+
+func TestDetectPlatformOwnExecutable(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("Executable format not detected on", runtime.GOOS)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip("Cannot locate test executable")
+	}
+
+	f, err := os.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Static Linux binaries don't say which system they are for
+	goos, goarch := detectPlatform(readHeader(f))
+	if (goos != runtime.GOOS && !(goos == "" && runtime.GOOS == "linux")) || goarch != runtime.GOARCH {
+		t.Errorf("Expected %s/%s, got %s/%s", runtime.GOOS, runtime.GOARCH, goos, goarch)
+	}
+}
@@ -1,9 +1,7 @@
 package archive
 
 import (
-	"archive/tar"
-	"archive/zip"
-	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,158 +9,158 @@ import (
 	"strings"
 )
 
-// DetectBinaries inspects an archive and returns paths to executable files
-func DetectBinaries(archivePath string) ([]string, error) {
-	ext := strings.ToLower(filepath.Ext(archivePath))
-	
-	switch ext {
-	case ".zip":
-		return detectBinariesZip(archivePath)
-	case ".gz", ".tgz":
-		return detectBinariesTarGz(archivePath)
-	case ".tar":
-		return detectBinariesTar(archivePath)
-	default:
-		return nil, fmt.Errorf("unsupported archive format: %s", ext)
-	}
+// ErrNoBinaries is returned when an archive has nothing to install
+var ErrNoBinaries = errors.New("no executable binaries found in archive")
+
+// Entry describes a file in an archive
+type Entry struct {
+	Name string
+	Size int64
+	Mode os.FileMode
+	// OS and Arch are the platform an executable was built for, if known
+	OS   string
+	Arch string
 }
 
-// Extract extracts specific files from an archive to destination
-func Extract(archivePath, destDir string, files []string) ([]string, error) {
-	ext := strings.ToLower(filepath.Ext(archivePath))
-	
-	switch ext {
-	case ".zip":
-		return extractZip(archivePath, destDir, files)
-	case ".gz", ".tgz":
-		return extractTarGz(archivePath, destDir, files)
-	case ".tar":
-		return extractTar(archivePath, destDir, files)
+// Type returns "file", "dir", "symlink" or "other"
+func (e Entry) Type() string {
+	switch {
+	case e.Mode.IsRegular():
+		return "file"
+	case e.Mode.IsDir():
+		return "dir"
+	case e.Mode&os.ModeSymlink != 0:
+		return "symlink"
 	default:
-		return nil, fmt.Errorf("unsupported archive format: %s", ext)
+		return "other"
 	}
 }
 
-func detectBinariesZip(path string) ([]string, error) {
-	r, err := zip.OpenReader(path)
-	if err != nil {
-		return nil, err
-	}
-	defer r.Close()
-	
-	var binaries []string
-	for _, f := range r.File {
-		if isExecutable(f.Name, f.Mode()) && !f.FileInfo().IsDir() {
-			binaries = append(binaries, f.Name)
-		}
+// IsBinary reports whether the entry looks like an executable to install
+func (e Entry) IsBinary() bool {
+	binary, _ := e.Classify()
+	return binary
+}
+
+// Classify reports whether the entry looks like an executable to install,
+// and why
+func (e Entry) Classify() (binary bool, reason string) {
+	if e.Mode.IsDir() {
+		return false, "directory"
 	}
-	
-	return binaries, nil
+	return isExecutable(e.Name, e.Mode)
 }
 
-func detectBinariesTarGz(path string) ([]string, error) {
-	f, err := os.Open(path)
+// List returns every entry in an archive. The platform of entries that look
+// like executables is detected from their headers.
+func List(archivePath string) ([]Entry, error) {
+	format, err := FormatOf(archivePath)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 	
-	gzr, err := gzip.NewReader(f)
+	var entries []Entry
+	err = format.Walk(archivePath, func(e Entry, open func() (io.ReadCloser, error)) error {
+		if e.IsBinary() && e.Mode.IsRegular() {
+			rc, err := open()
+			if err != nil {
+				return err
+			}
+			e.OS, e.Arch = detectPlatform(readHeader(rc))
+			rc.Close()
+		}
+		
+		entries = append(entries, e)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer gzr.Close()
 	
-	return detectBinariesFromTar(tar.NewReader(gzr))
+	return entries, nil
 }
 
-func detectBinariesTar(path string) ([]string, error) {
-	f, err := os.Open(path)
+// DetectBinaries inspects an archive and returns paths to executable files
+func DetectBinaries(archivePath string) ([]string, error) {
+	entries, err := List(archivePath)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 	
-	return detectBinariesFromTar(tar.NewReader(f))
+	return binaryNames(entries), nil
 }
 
-func detectBinariesFromTar(tr *tar.Reader) ([]string, error) {
-	var binaries []string
-	
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		
-		if isExecutable(header.Name, header.FileInfo().Mode()) && !header.FileInfo().IsDir() {
-			binaries = append(binaries, header.Name)
+// Binaries returns the entries that look like executables to install
+func Binaries(entries []Entry) []Entry {
+	var binaries []Entry
+	for _, e := range entries {
+		if e.IsBinary() {
+			binaries = append(binaries, e)
 		}
 	}
-	
-	return binaries, nil
+	return binaries
 }
 
-func isExecutable(name string, mode os.FileMode) bool {
-	// Check if in bin/ directory
-	if strings.Contains(name, "/bin/") || strings.HasPrefix(name, "bin/") {
-		return true
+func binaryNames(entries []Entry) []string {
+	var names []string
+	for _, e := range Binaries(entries) {
+		names = append(names, e.Name)
 	}
-	
-	// Check if file has executable bit
-	if mode&0111 != 0 {
-		// Exclude common non-binary executables
-		ext := strings.ToLower(filepath.Ext(name))
-		if ext == ".sh" || ext == ".py" || ext == ".rb" || ext == ".pl" {
-			return false
-		}
-		return true
+	return names
+}
+
+// Extract extracts specific files from an archive to destination
+func Extract(archivePath, destDir string, files []string) ([]string, error) {
+	names := make(map[string]string)
+	for _, f := range files {
+		names[f] = filepath.Base(f)
 	}
 	
-	return false
+	return ExtractAs(archivePath, destDir, names)
 }
 
-func extractZip(archivePath, destDir string, files []string) ([]string, error) {
-	r, err := zip.OpenReader(archivePath)
-	if err != nil {
-		return nil, err
+// ExtractAs extracts the archive entries that are keys of files to
+// destination, each under the file name it maps to
+func ExtractAs(archivePath, destDir string, files map[string]string) ([]string, error) {
+	for _, name := range files {
+		if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+			return nil, fmt.Errorf("invalid file name: %q", name)
+		}
 	}
-	defer r.Close()
 	
-	fileSet := make(map[string]bool)
-	for _, f := range files {
-		fileSet[f] = true
+	format, err := FormatOf(archivePath)
+	if err != nil {
+		return nil, err
 	}
 	
 	var extracted []string
-	for _, f := range r.File {
-		if !fileSet[f.Name] {
-			continue
+	err = format.Walk(archivePath, func(e Entry, open func() (io.ReadCloser, error)) error {
+		name, ok := files[e.Name]
+		if !ok {
+			return nil
 		}
 		
-		destPath := filepath.Join(destDir, filepath.Base(f.Name))
-		
-		if err := extractZipFile(f, destPath); err != nil {
-			return extracted, err
+		destPath := filepath.Join(destDir, name)
+		if err := extractEntry(e, open, destPath); err != nil {
+			return err
 		}
 		
 		extracted = append(extracted, destPath)
-	}
+		return nil
+	})
 	
-	return extracted, nil
+	return extracted, err
 }
 
-func extractZipFile(f *zip.File, destPath string) error {
-	rc, err := f.Open()
+// extractEntry writes the content of an entry to destPath
+func extractEntry(e Entry, open func() (io.ReadCloser, error), destPath string) error {
+	rc, err := open()
 	if err != nil {
 		return err
 	}
 	defer rc.Close()
 	
-	outFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+	outFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, e.Mode.Perm())
 	if err != nil {
 		return err
 	}
@@ -172,68 +170,21 @@ func extractZipFile(f *zip.File, destPath string) error {
 	return err
 }
 
-func extractTarGz(archivePath, destDir string, files []string) ([]string, error) {
-	f, err := os.Open(archivePath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	
-	gzr, err := gzip.NewReader(f)
-	if err != nil {
-		return nil, err
-	}
-	defer gzr.Close()
-	
-	return extractFromTar(tar.NewReader(gzr), destDir, files)
-}
-
-func extractTar(archivePath, destDir string, files []string) ([]string, error) {
-	f, err := os.Open(archivePath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	
-	return extractFromTar(tar.NewReader(f), destDir, files)
-}
-
-func extractFromTar(tr *tar.Reader, destDir string, files []string) ([]string, error) {
-	fileSet := make(map[string]bool)
-	for _, f := range files {
-		fileSet[f] = true
+func isExecutable(name string, mode os.FileMode) (bool, string) {
+	// Check if in bin/ directory
+	if strings.Contains(name, "/bin/") || strings.HasPrefix(name, "bin/") {
+		return true, "in a bin directory"
 	}
 	
-	var extracted []string
-	
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return extracted, err
-		}
-		
-		if !fileSet[header.Name] {
-			continue
-		}
-		
-		destPath := filepath.Join(destDir, filepath.Base(header.Name))
-		
-		outFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode())
-		if err != nil {
-			return extracted, err
-		}
-		
-		if _, err := io.Copy(outFile, tr); err != nil {
-			outFile.Close()
-			return extracted, err
+	// Check if file has executable bit
+	if mode&0111 != 0 {
+		// Exclude common non-binary executables
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext == ".sh" || ext == ".py" || ext == ".rb" || ext == ".pl" {
+			return false, "executable, but a " + ext + " script"
 		}
-		outFile.Close()
-		
-		extracted = append(extracted, destPath)
+		return true, "executable bit set"
 	}
 	
-	return extracted, nil
+	return false, "not executable and not in a bin directory"
 }
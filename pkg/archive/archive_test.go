@@ -26,13 +26,21 @@ func TestIsExecutable(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isExecutable(tt.path, tt.mode)
+			result, reason := isExecutable(tt.path, tt.mode)
 			if result != tt.expected {
 				t.Errorf("isExecutable(%q, %v) = %v; want %v",
 					tt.path, tt.mode, result, tt.expected)
 			}
+			if reason == "" {
+				t.Errorf("isExecutable(%q, %v) gave no reason", tt.path, tt.mode)
+			}
 		})
 	}
+
+	dir := Entry{Name: "bin/", Mode: os.ModeDir | 0755}
+	if binary, reason := dir.Classify(); binary || reason != "directory" {
+		t.Errorf("Classify() of a directory = %v, %q", binary, reason)
+	}
 }
 
 func TestDetectBinariesZip(t *testing.T) {
@@ -74,9 +82,9 @@ func TestDetectBinariesZip(t *testing.T) {
 	}
 
 	// Test detection
-	binaries, err := detectBinariesZip(zipPath)
+	binaries, err := DetectBinaries(zipPath)
 	if err != nil {
-		t.Fatalf("detectBinariesZip failed: %v", err)
+		t.Fatalf("DetectBinaries failed: %v", err)
 	}
 
 	if len(binaries) != 1 {
@@ -134,9 +142,9 @@ func TestDetectBinariesTar(t *testing.T) {
 	}
 
 	// Test detection
-	binaries, err := detectBinariesTar(tarPath)
+	binaries, err := DetectBinaries(tarPath)
 	if err != nil {
-		t.Fatalf("detectBinariesTar failed: %v", err)
+		t.Fatalf("DetectBinaries failed: %v", err)
 	}
 
 	if len(binaries) != 1 {
@@ -183,9 +191,9 @@ func TestDetectBinariesTarGz(t *testing.T) {
 	}
 
 	// Test detection
-	binaries, err := detectBinariesTarGz(tarGzPath)
+	binaries, err := DetectBinaries(tarGzPath)
 	if err != nil {
-		t.Fatalf("detectBinariesTarGz failed: %v", err)
+		t.Fatalf("DetectBinaries failed: %v", err)
 	}
 
 	if len(binaries) != 1 {
@@ -251,3 +259,55 @@ func TestExtract(t *testing.T) {
 		t.Errorf("Expected 'test content', got '%s'", string(content))
 	}
 }
+
+func TestExtractAs(t *testing.T) {
+	tmpDir := t.TempDir()
+	tarPath := filepath.Join(tmpDir, "test.tar")
+	destDir := filepath.Join(tmpDir, "dest")
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tar.NewWriter(f)
+	for _, name := range []string{"app/bin/tool", "app/bin/other"} {
+		content := []byte(name)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	f.Close()
+
+	extracted, err := ExtractAs(tarPath, destDir, map[string]string{"app/bin/tool": "renamed"})
+	if err != nil {
+		t.Fatalf("ExtractAs failed: %v", err)
+	}
+
+	if len(extracted) != 1 || extracted[0] != filepath.Join(destDir, "renamed") {
+		t.Errorf("Expected only renamed to be extracted, got %v", extracted)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "renamed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "app/bin/tool" {
+		t.Errorf("Unexpected content: %q", string(content))
+	}
+
+	// Names must not point outside the destination
+	for _, name := range []string{"../escape", "sub/dir", "", ".."} {
+		if _, err := ExtractAs(tarPath, destDir, map[string]string{"app/bin/tool": name}); err == nil {
+			t.Errorf("Expected error for name %q", name)
+		}
+	}
+}
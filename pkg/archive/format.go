@@ -0,0 +1,152 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SniffSize is how many bytes of an archive Sniff looks at
+const SniffSize = 512
+
+var (
+	// ErrUnsupportedFormat is returned for archives no registered Format
+	// reads
+	ErrUnsupportedFormat = errors.New("unsupported archive format")
+	// ErrCorrupt is returned when an archive can't be read as its format
+	ErrCorrupt = errors.New("corrupt archive")
+)
+
+// Format reads one kind of archive. Formats are found by file extension, or
+// by sniffing the first bytes of an archive read from a stream.
+type Format interface {
+	// Name identifies the format in output, e.g. "tar.gz"
+	Name() string
+	// Extensions are the file name suffixes of the format, e.g. ".tgz"
+	Extensions() []string
+	// Sniff reports whether head, up to SniffSize bytes from the start of
+	// an archive, is in this format
+	Sniff(head []byte) bool
+	// Walk calls fn for every entry of the archive at path, in order
+	Walk(path string, fn WalkFunc) error
+}
+
+// StreamFormat is a Format that can be read in a single pass, such as from
+// stdin. Archives in other formats are spooled to a temporary file first.
+type StreamFormat interface {
+	Format
+	// WalkReader is Walk for an archive read from r
+	WalkReader(r io.Reader, fn WalkFunc) error
+}
+
+// WalkFunc is called for each entry of an archive. open returns the content
+// of the entry and is only valid until WalkFunc returns. An error stops the
+// walk and is returned by it.
+type WalkFunc func(e Entry, open func() (io.ReadCloser, error)) error
+
+var (
+	formatsMu sync.RWMutex
+	formats   = []Format{zipFormat{}, tarFormat{}, tarFormat{gzip: true}}
+)
+
+// Register adds a format, so archives in it can be inspected and installed.
+// Formats registered later take precedence when extensions overlap. It
+// panics if a format with the same name is already registered.
+func Register(f Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	
+	for _, existing := range formats {
+		if existing.Name() == f.Name() {
+			panic(fmt.Sprintf("archive: format %s registered twice", f.Name()))
+		}
+	}
+	formats = append(formats, f)
+}
+
+// Formats returns every registered format, latest first
+func Formats() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	
+	list := make([]Format, 0, len(formats))
+	for i := len(formats) - 1; i >= 0; i-- {
+		list = append(list, formats[i])
+	}
+	return list
+}
+
+// Lookup returns the format with the given name
+func Lookup(name string) (Format, bool) {
+	for _, f := range Formats() {
+		if f.Name() == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// FormatOf returns the format of an archive by its file name. The longest
+// matching extension wins, so ".tar.gz" beats ".gz".
+func FormatOf(archivePath string) (Format, error) {
+	name := strings.ToLower(filepath.Base(archivePath))
+	
+	var match Format
+	longest := 0
+	for _, f := range Formats() {
+		for _, ext := range f.Extensions() {
+			if len(ext) > longest && strings.HasSuffix(name, strings.ToLower(ext)) {
+				match, longest = f, len(ext)
+			}
+		}
+	}
+	
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(archivePath))
+	}
+	return match, nil
+}
+
+// Sniff returns the format of an archive from its first bytes
+func Sniff(head []byte) (Format, error) {
+	var names []string
+	for _, f := range Formats() {
+		if f.Sniff(head) {
+			return f, nil
+		}
+		names = append(names, f.Name())
+	}
+	return nil, fmt.Errorf("%w: expected one of %s", ErrUnsupportedFormat, strings.Join(names, ", "))
+}
+
+// Corrupt marks err, from reading an archive, as ErrCorrupt. Errors that
+// say nothing about the archive itself, such as a missing file or a
+// cancelled context, are returned as is. Formats use it for the errors of
+// Walk and of the readers they open.
+func Corrupt(err error) error {
+	switch {
+	case err == nil, err == io.EOF,
+		errors.Is(err, ErrCorrupt),
+		errors.Is(err, fs.ErrNotExist),
+		errors.Is(err, fs.ErrPermission),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrCorrupt, err)
+}
+
+// corruptReader marks the errors of reading an archive entry as ErrCorrupt
+type corruptReader struct {
+	r io.Reader
+}
+
+func (c corruptReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	return n, Corrupt(err)
+}
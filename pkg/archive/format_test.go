@@ -0,0 +1,122 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// linesFormat is a toy format: a "LINES" header followed by one executable
+// entry per line, whose content is its own name
+type linesFormat struct{}
+
+func (linesFormat) Name() string { return "lines" }
+
+func (linesFormat) Extensions() []string { return []string{".lines"} }
+
+func (linesFormat) Sniff(head []byte) bool { return bytes.HasPrefix(head, []byte("LINES\n")) }
+
+func (linesFormat) Walk(path string, fn WalkFunc) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range strings.Fields(strings.TrimPrefix(string(data), "LINES\n")) {
+		content := name
+		open := func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(content)), nil }
+		if err := fn(Entry{Name: name, Size: int64(len(name)), Mode: 0755}, open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	// Registration is global, so only the first run of the test registers
+	if _, ok := Lookup("lines"); !ok {
+		Register(linesFormat{})
+	}
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tools.lines")
+	data := []byte("LINES\nbin/one\nbin/two\n")
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	format, err := FormatOf(archivePath)
+	if err != nil {
+		t.Fatalf("FormatOf failed: %v", err)
+	}
+	if format.Name() != "lines" {
+		t.Errorf("Expected lines format, got %s", format.Name())
+	}
+
+	binaries, err := DetectBinaries(archivePath)
+	if err != nil {
+		t.Fatalf("DetectBinaries failed: %v", err)
+	}
+	if len(binaries) != 2 {
+		t.Errorf("Expected 2 binaries, got %v", binaries)
+	}
+
+	// Formats that can't stream are spooled to a file
+	s, err := StageStream(bytes.NewReader(data), dir, nil)
+	if err != nil {
+		t.Fatalf("StageStream failed: %v", err)
+	}
+	defer s.Cleanup()
+
+	if s.Format != "lines" {
+		t.Errorf("Expected lines format, got %s", s.Format)
+	}
+	installed, err := s.Install(dir, []string{"bin/two"})
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if content, _ := os.ReadFile(installed[0]); string(content) != "bin/two" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	if _, err := StageReader(bytes.NewReader(data), "lines", dir, nil); err == nil {
+		t.Error("Expected staging a format that can't stream from a reader to fail")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a format twice to panic")
+		}
+	}()
+	Register(linesFormat{})
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"tool.zip":      "zip",
+		"tool.tar":      "tar",
+		"tool.tar.gz":   "tar.gz",
+		"TOOL.TGZ":      "tar.gz",
+		"tool-1.0.gz":   "tar.gz",
+		"dir.zip/x.tar": "tar",
+	}
+
+	for path, want := range tests {
+		format, err := FormatOf(path)
+		if err != nil {
+			t.Errorf("FormatOf(%s) failed: %v", path, err)
+			continue
+		}
+		if format.Name() != want {
+			t.Errorf("FormatOf(%s) = %s, want %s", path, format.Name(), want)
+		}
+	}
+
+	if _, err := FormatOf("tool.rar"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected an unknown extension to fail with ErrUnsupportedFormat, got %v", err)
+	}
+}
@@ -0,0 +1,355 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/repoleved08/bii/pkg/progress"
+)
+
+// Staged is an archive whose binaries were extracted to a staging
+// directory, so they can be installed without reading the archive again
+type Staged struct {
+	// Dir is the staging directory
+	Dir string
+	// Format is the name of the archive's format, e.g. "tar.gz"
+	Format string
+	// SHA256 is the hex-encoded digest of the archive
+	SHA256  string
+	Entries []Entry
+	// files maps binary entry names to their staged paths
+	files map[string]string
+	// want tells which regular files to stage
+	want func(Entry) bool
+	// progress is sent an event for every binary staged
+	progress progress.Func
+}
+
+// Stage lists an archive and extracts every binary in it to a new
+// directory in parent (the system temp directory if empty). Formats that
+// can stream, like tar, are read in a single pass. Staging on the same
+// filesystem as the destination lets Install move files into place with a
+// rename. fn, which may be nil, is sent progress events as binaries are
+// written.
+func Stage(archivePath, parent string, fn progress.Func) (*Staged, error) {
+	return stage(archivePath, parent, Entry.IsBinary, fn)
+}
+
+// StageEntries is like Stage, but stages the named file entries whether or
+// not they look like binaries
+func StageEntries(archivePath, parent string, names []string, fn progress.Func) (*Staged, error) {
+	want := make(map[string]bool)
+	for _, name := range names {
+		want[name] = true
+	}
+	
+	return stage(archivePath, parent, func(e Entry) bool { return want[e.Name] }, fn)
+}
+
+func stage(archivePath, parent string, want func(Entry) bool, fn progress.Func) (*Staged, error) {
+	format, err := FormatOf(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	
+	sf, ok := format.(StreamFormat)
+	if !ok {
+		return stageFile(format, archivePath, parent, want, fn)
+	}
+	
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	
+	return stageReader(sf, f, parent, want, fn)
+}
+
+// StageReader stages an archive in the named format read from r in a
+// single pass. Formats that need random access, like zip, can't be staged
+// from a stream.
+func StageReader(r io.Reader, format, parent string, fn progress.Func) (*Staged, error) {
+	f, ok := Lookup(format)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	
+	sf, ok := f.(StreamFormat)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s archives can't be staged from a stream", ErrUnsupportedFormat, format)
+	}
+	
+	return stageReader(sf, r, parent, Entry.IsBinary, fn)
+}
+
+// StageStream stages an archive of unknown format, such as one piped to
+// stdin. The format is sniffed from the first bytes; formats that can
+// stream are staged as the archive comes in, and others are spooled to a
+// temporary file first.
+func StageStream(r io.Reader, parent string, fn progress.Func) (*Staged, error) {
+	br := bufio.NewReaderSize(r, SniffSize)
+	head, err := br.Peek(SniffSize)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	
+	format, err := Sniff(head)
+	if err != nil {
+		return nil, err
+	}
+	
+	if sf, ok := format.(StreamFormat); ok {
+		return stageReader(sf, br, parent, Entry.IsBinary, fn)
+	}
+	
+	spool, err := os.CreateTemp(parent, ".bii-stdin-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	
+	if _, err := io.Copy(spool, br); err != nil {
+		spool.Close()
+		return nil, err
+	}
+	if err := spool.Close(); err != nil {
+		return nil, err
+	}
+	
+	return stageFile(format, spool.Name(), parent, Entry.IsBinary, fn)
+}
+
+func stageReader(format StreamFormat, r io.Reader, parent string, want func(Entry) bool, fn progress.Func) (*Staged, error) {
+	h := sha256.New()
+	in := io.TeeReader(r, h)
+	
+	s, err := newStaged(parent, format.Name(), want, fn)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := format.WalkReader(in, s.add); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	
+	// Hash whatever follows the end of the archive too, such as padding
+	if _, err := io.Copy(io.Discard, in); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	s.SHA256 = hex.EncodeToString(h.Sum(nil))
+	
+	return s, nil
+}
+
+func stageFile(format Format, archivePath, parent string, want func(Entry) bool, fn progress.Func) (*Staged, error) {
+	digest, err := fileSHA256(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	
+	s, err := newStaged(parent, format.Name(), want, fn)
+	if err != nil {
+		return nil, err
+	}
+	s.SHA256 = digest
+	
+	if err := format.Walk(archivePath, s.add); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	
+	return s, nil
+}
+
+func newStaged(parent, format string, want func(Entry) bool, fn progress.Func) (*Staged, error) {
+	dir, err := os.MkdirTemp(parent, ".bii-staging-*")
+	if err != nil {
+		return nil, err
+	}
+	return &Staged{Dir: dir, Format: format, files: make(map[string]string), want: want, progress: fn}, nil
+}
+
+// add records an entry and writes it to the staging directory if it is a
+// wanted regular file, detecting its platform from the bytes already read
+func (s *Staged) add(entry Entry, open func() (io.ReadCloser, error)) error {
+	if s.want(entry) && entry.Mode.IsRegular() {
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		
+		buf := make([]byte, headerSize)
+		n, err := io.ReadFull(rc, buf)
+		if err != nil && err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		entry.OS, entry.Arch = detectPlatform(buf[:n])
+		
+		if err := s.write(entry, io.MultiReader(bytes.NewReader(buf[:n]), rc)); err != nil {
+			return err
+		}
+	}
+	
+	s.Entries = append(s.Entries, entry)
+	return nil
+}
+
+// write stages the content of a binary entry
+func (s *Staged) write(entry Entry, r io.Reader) error {
+	path := filepath.Join(s.Dir, strconv.Itoa(len(s.files)))
+	
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, entry.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	
+	event := progress.Event{Entry: entry.Name, Total: entry.Size}
+	s.progress.Emit(progress.Event{Kind: progress.EntryStarted, Entry: entry.Name, Total: entry.Size})
+	if _, err := io.Copy(s.progress.Writer(out, event), r); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	s.progress.Emit(progress.Event{Kind: progress.EntryDone, Entry: entry.Name, Bytes: entry.Size, Total: entry.Size})
+	
+	s.files[entry.Name] = path
+	return nil
+}
+
+// Path returns where a binary entry was staged
+func (s *Staged) Path(entry string) (string, bool) {
+	path, ok := s.files[entry]
+	return path, ok
+}
+
+// Install moves staged binaries to destination under their base names and
+// returns the installed paths
+func (s *Staged) Install(destDir string, files []string) ([]string, error) {
+	var installed []string
+	
+	for _, entry := range files {
+		src, ok := s.files[entry]
+		if !ok {
+			return installed, fmt.Errorf("%s was not staged", entry)
+		}
+		
+		dest := filepath.Join(destDir, filepath.Base(entry))
+		if err := moveFile(src, dest); err != nil {
+			return installed, err
+		}
+		delete(s.files, entry)
+		
+		installed = append(installed, dest)
+	}
+	
+	return installed, nil
+}
+
+// InstallAs moves the staged entries that are keys of files to destDir,
+// each under the file name it maps to, and returns the installed paths in
+// the order of their entry names
+func (s *Staged) InstallAs(destDir string, files map[string]string) ([]string, error) {
+	var entries []string
+	for entry, name := range files {
+		if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+			return nil, fmt.Errorf("invalid file name: %q", name)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	
+	var installed []string
+	for _, entry := range entries {
+		src, ok := s.files[entry]
+		if !ok {
+			return installed, fmt.Errorf("%s was not staged", entry)
+		}
+		
+		dest := filepath.Join(destDir, files[entry])
+		if err := moveFile(src, dest); err != nil {
+			return installed, err
+		}
+		delete(s.files, entry)
+		
+		installed = append(installed, dest)
+	}
+	
+	return installed, nil
+}
+
+// Cleanup removes the staging directory and anything left in it
+func (s *Staged) Cleanup() error {
+	return os.RemoveAll(s.Dir)
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// moveFile renames src to dest. When they are on different filesystems,
+// src is copied next to dest first and renamed over it, so dest is never
+// left half written and a running binary can be replaced.
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+	
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	
+	out, err := os.CreateTemp(filepath.Dir(dest), ".bii-"+filepath.Base(dest)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Chmod(info.Mode().Perm()); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(out.Name(), dest); err != nil {
+		return err
+	}
+	
+	return os.Remove(src)
+}
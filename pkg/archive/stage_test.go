@@ -0,0 +1,329 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/repoleved08/bii/pkg/progress"
+)
+
+// writeTestTarGz creates a tar.gz archive with the given files and modes
+func writeTestTarGz(t testing.TB, path string, files map[string][]byte, modes map[string]int64) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		mode := modes[name]
+		if mode == 0 {
+			mode = 0644
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStageTarGz(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+
+	// An ELF header for linux/amd64, followed by enough content to cross
+	// the header buffer
+	elf := make([]byte, headerSize+100)
+	copy(elf, []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 3})
+	elf[18] = 0x3e
+	elf[len(elf)-1] = 'z'
+
+	writeTestTarGz(t, archivePath, map[string][]byte{
+		"app/bin/tool": elf,
+		"app/run":      []byte("short"),
+		"app/README":   []byte("docs"),
+	}, map[string]int64{"app/run": 0755})
+
+	dest := filepath.Join(dir, "bin")
+	if err := os.Mkdir(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Stage(archivePath, dest, nil)
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	defer s.Cleanup()
+
+	if len(s.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(s.Entries))
+	}
+	binaries := Binaries(s.Entries)
+	if len(binaries) != 2 {
+		t.Fatalf("Expected 2 binaries, got %v", binaries)
+	}
+	for _, b := range binaries {
+		if b.Name == "app/bin/tool" && (b.OS != "linux" || b.Arch != "amd64") {
+			t.Errorf("Expected linux/amd64, got %s/%s", b.OS, b.Arch)
+		}
+	}
+	if _, ok := s.Path("app/README"); ok {
+		t.Error("Expected non-binary not to be staged")
+	}
+
+	installed, err := s.Install(dest, []string{"app/bin/tool", "app/run"})
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if len(installed) != 2 {
+		t.Fatalf("Expected 2 installed files, got %v", installed)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dest, "tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, elf) {
+		t.Error("Expected staged content to match the archive")
+	}
+	if content, _ := os.ReadFile(filepath.Join(dest, "run")); string(content) != "short" {
+		t.Errorf("Expected short file to be staged whole, got %q", content)
+	}
+	if info, _ := os.Stat(filepath.Join(dest, "run")); info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755, got %04o", info.Mode().Perm())
+	}
+
+	if _, err := s.Install(dest, []string{"app/bin/tool"}); err == nil {
+		t.Error("Expected installing twice to fail")
+	}
+
+	if err := s.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.Dir); !os.IsNotExist(err) {
+		t.Error("Expected staging directory to be removed")
+	}
+}
+
+func TestStageZip(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.zip")
+
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	fw, err := w.CreateHeader(&zip.FileHeader{Name: "bin/tool", Method: zip.Deflate, ExternalAttrs: 0755 << 16, CreatorVersion: 3 << 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("zipped"))
+	w.Close()
+	f.Close()
+
+	s, err := Stage(archivePath, "", nil)
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	defer s.Cleanup()
+
+	installed, err := s.Install(dir, []string{"bin/tool"})
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if content, _ := os.ReadFile(installed[0]); string(content) != "zipped" {
+		t.Errorf("Unexpected content %q", content)
+	}
+}
+
+func TestStageProgress(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "tool.tar.gz")
+	writeTestTarGz(t, archivePath, map[string][]byte{
+		"bin/tool": bytes.Repeat([]byte("x"), 100000),
+		"README":   []byte("docs"),
+	}, nil)
+
+	var events []progress.Event
+	s, err := Stage(archivePath, "", func(e progress.Event) { events = append(events, e) })
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	defer s.Cleanup()
+
+	// Only binaries are written, so only they are reported
+	if len(events) < 3 {
+		t.Fatalf("Expected at least 3 events, got %v", events)
+	}
+	if first := events[0]; first.Kind != progress.EntryStarted || first.Entry != "bin/tool" || first.Total != 100000 {
+		t.Errorf("Unexpected first event %+v", first)
+	}
+	if written := events[len(events)-2]; written.Kind != progress.BytesWritten || written.Bytes != 100000 {
+		t.Errorf("Expected every byte to be reported, got %+v", written)
+	}
+	if last := events[len(events)-1]; last.Kind != progress.EntryDone || last.Entry != "bin/tool" {
+		t.Errorf("Unexpected last event %+v", last)
+	}
+}
+
+func TestStageReaderZip(t *testing.T) {
+	if _, err := StageReader(bytes.NewReader(nil), "zip", t.TempDir(), nil); err == nil {
+		t.Error("Expected zip streams to be refused")
+	}
+}
+
+func TestStageStream(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{"bin/tool": []byte("streamed")}
+	modes := map[string]int64{"bin/tool": 0755}
+
+	tgzPath := filepath.Join(dir, "tool.tar.gz")
+	writeTestTarGz(t, tgzPath, files, modes)
+	tgz, err := os.ReadFile(tgzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gzr, err := gzip.NewReader(bytes.NewReader(tgz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarball, err := io.ReadAll(gzr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: "bin/tool", Method: zip.Deflate, ExternalAttrs: 0755 << 16, CreatorVersion: 3 << 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("streamed"))
+	zw.Close()
+
+	tests := []struct {
+		format string
+		data   []byte
+	}{
+		{"tar.gz", tgz},
+		{"tar", tarball},
+		{"zip", zipped.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			s, err := StageStream(bytes.NewReader(tt.data), t.TempDir(), nil)
+			if err != nil {
+				t.Fatalf("StageStream failed: %v", err)
+			}
+			defer s.Cleanup()
+
+			if s.Format != tt.format {
+				t.Errorf("Expected format %s, got %s", tt.format, s.Format)
+			}
+			digest := sha256.Sum256(tt.data)
+			if s.SHA256 != hex.EncodeToString(digest[:]) {
+				t.Errorf("Expected the digest of the whole stream, got %s", s.SHA256)
+			}
+
+			path, ok := s.Path("bin/tool")
+			if !ok {
+				t.Fatal("Expected bin/tool to be staged")
+			}
+			if content, _ := os.ReadFile(path); string(content) != "streamed" {
+				t.Errorf("Unexpected content %q", content)
+			}
+		})
+	}
+
+	if _, err := StageStream(bytes.NewReader([]byte("not an archive")), t.TempDir(), nil); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected an unrecognised stream to fail with ErrUnsupportedFormat, got %v", err)
+	}
+
+	// A truncated archive is sniffed, but can't be read
+	if _, err := StageStream(bytes.NewReader(tgz[:len(tgz)/2]), t.TempDir(), nil); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected a truncated stream to fail with ErrCorrupt, got %v", err)
+	}
+}
+
+// benchmarkArchive creates a tar.gz with one large binary and many small
+// files, like a toolchain
+func benchmarkArchive(b *testing.B) string {
+	b.Helper()
+
+	big := make([]byte, 32<<20)
+	if _, err := rand.Read(big[:1<<20]); err != nil {
+		b.Fatal(err)
+	}
+
+	files := map[string][]byte{"toolchain/bin/tool": big}
+	modes := map[string]int64{"toolchain/bin/tool": 0755}
+	for i := 0; i < 500; i++ {
+		files[fmt.Sprintf("toolchain/lib/file%d.txt", i)] = bytes.Repeat([]byte("x"), 4096)
+	}
+
+	path := filepath.Join(b.TempDir(), "toolchain.tar.gz")
+	writeTestTarGz(b, path, files, modes)
+	return path
+}
+
+// BenchmarkInstallTwoPass lists the archive, then extracts the binaries,
+// decompressing it twice
+func BenchmarkInstallTwoPass(b *testing.B) {
+	archivePath := benchmarkArchive(b)
+	dest := b.TempDir()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		entries, err := List(archivePath)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Extract(archivePath, dest, binaryNames(entries)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkInstallStaged lists and stages the binaries in one pass, then
+// moves them into place
+func BenchmarkInstallStaged(b *testing.B) {
+	archivePath := benchmarkArchive(b)
+	dest := b.TempDir()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s, err := Stage(archivePath, dest, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.Install(dest, binaryNames(s.Entries)); err != nil {
+			b.Fatal(err)
+		}
+		s.Cleanup()
+	}
+}
@@ -0,0 +1,79 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// tarFormat reads tar archives, optionally gzip-compressed, in one pass
+type tarFormat struct {
+	gzip bool
+}
+
+func (t tarFormat) Name() string {
+	if t.gzip {
+		return "tar.gz"
+	}
+	return "tar"
+}
+
+func (t tarFormat) Extensions() []string {
+	if t.gzip {
+		return []string{".tar.gz", ".tgz", ".gz"}
+	}
+	return []string{".tar"}
+}
+
+func (t tarFormat) Sniff(head []byte) bool {
+	if t.gzip {
+		return bytes.HasPrefix(head, []byte{0x1f, 0x8b})
+	}
+	return len(head) >= 262 && string(head[257:262]) == "ustar"
+}
+
+func (t tarFormat) Walk(path string, fn WalkFunc) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	
+	return t.WalkReader(f, fn)
+}
+
+func (t tarFormat) WalkReader(r io.Reader, fn WalkFunc) error {
+	if t.gzip {
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return Corrupt(err)
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	
+	tr := tar.NewReader(r)
+	open := func() (io.ReadCloser, error) { return io.NopCloser(corruptReader{tr}), nil }
+	
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return Corrupt(err)
+		}
+		
+		entry := Entry{
+			Name: header.Name,
+			Size: header.Size,
+			Mode: header.FileInfo().Mode(),
+		}
+		
+		if err := fn(entry, open); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+)
+
+// zipFormat reads zip archives, which need random access
+type zipFormat struct{}
+
+func (zipFormat) Name() string { return "zip" }
+
+func (zipFormat) Extensions() []string { return []string{".zip"} }
+
+func (zipFormat) Sniff(head []byte) bool {
+	// An empty archive is only an end of central directory record
+	return bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06"))
+}
+
+func (zipFormat) Walk(path string, fn WalkFunc) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return Corrupt(err)
+	}
+	defer r.Close()
+	
+	for _, f := range r.File {
+		entry := Entry{
+			Name: f.Name,
+			Size: int64(f.UncompressedSize64),
+			Mode: f.Mode(),
+		}
+		
+		open := func() (io.ReadCloser, error) {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, Corrupt(err)
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{corruptReader{rc}, rc}, nil
+		}
+		if err := fn(entry, open); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
@@ -0,0 +1,224 @@
+// Package bii installs binaries from archives and adds their directory to
+// PATH. It is what the bii command is built on, for programs that want to
+// install tools in-process.
+//
+//	inst, err := bii.New(bii.WithDest("/opt/tools/bin"), bii.WithSkipPath())
+//	if err != nil {
+//		return err
+//	}
+//	result, err := inst.Install(ctx, "kubectl.tar.gz")
+//
+// Like the bii command, an Installer takes the lock that keeps bii
+// processes apart while it installs, and records the binaries it installs
+// in the state file, where bii sync and later runs find them.
+package bii
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/manifest"
+	"github.com/repoleved08/bii/pkg/progress"
+	"github.com/repoleved08/bii/pkg/prompt"
+	"github.com/repoleved08/bii/pkg/shell"
+	"github.com/repoleved08/bii/pkg/version"
+)
+
+var (
+	// ErrNotFound is returned when an archive doesn't exist
+	ErrNotFound = errors.New("archive not found")
+	// ErrNoBinaries is returned when an archive has nothing to install
+	ErrNoBinaries = archive.ErrNoBinaries
+	// ErrCancelled is returned when the Prompter declines an installation.
+	// It wraps prompt.ErrDeclined.
+	ErrCancelled = fmt.Errorf("installation %w", prompt.ErrDeclined)
+	// ErrRefused is returned when the conflict policy or the lock forbids
+	// an installation. It wraps the reason, such as installer.ErrExists or
+	// installer.ErrChecksumMismatch.
+	ErrRefused = errors.New("refusing to install")
+	// ErrDryRun is returned by Apply for a plan made on a dry run
+	ErrDryRun = errors.New("nothing is installed on a dry run")
+)
+
+// Installer installs binaries from archives. Its zero value isn't usable;
+// create one with New.
+type Installer struct {
+	dest     string
+	shell    string
+	prompter prompt.Prompter
+	logger   *slog.Logger
+	progress progress.Func
+	fsys     fs.FS
+	conflict installer.ConflictPolicy
+	lock     *manifest.Lock
+	esc      *installer.Escalator
+	skipPath bool
+	dryRun   bool
+	// probe is how long each version probe may run, or 0 not to probe
+	probe time.Duration
+	// noSandbox warns once that probes can't be isolated
+	noSandbox sync.Once
+	// lockTimeout is how long Lock waits for another bii process
+	lockTimeout time.Duration
+}
+
+// Option configures an Installer
+type Option func(*Installer)
+
+// WithDest sets the directory binaries are installed to. The default is
+// ~/.local/bin, or /usr/local/bin for system installs.
+func WithDest(dir string) Option {
+	return func(i *Installer) { i.dest = dir }
+}
+
+// WithShell sets the shell whose configuration puts the destination on
+// PATH. The default is the shell bii was started from.
+func WithShell(name string) Option {
+	return func(i *Installer) { i.shell = name }
+}
+
+// WithPrompter sets how Install asks for confirmation. The default answers
+// yes, since a program calling Install has already decided to install.
+func WithPrompter(p prompt.Prompter) Option {
+	return func(i *Installer) { i.prompter = p }
+}
+
+// WithLogger sets where the Installer logs what it does. The default
+// discards everything.
+func WithLogger(l *slog.Logger) Option {
+	return func(i *Installer) { i.logger = l }
+}
+
+// WithProgress sends fn an event as each binary is staged, verified and
+// installed, and when PATH is updated. fn may be called from several
+// goroutines when archives are prepared in parallel.
+func WithProgress(fn progress.Func) Option {
+	return func(i *Installer) { i.progress = fn }
+}
+
+// WithFS reads archives from fsys instead of the operating system. Their
+// format is then sniffed from their content rather than their name.
+func WithFS(fsys fs.FS) Option {
+	return func(i *Installer) { i.fsys = fsys }
+}
+
+// WithConflictPolicy sets what happens to files that already exist at the
+// destination. The default overwrites them.
+func WithConflictPolicy(p installer.ConflictPolicy) Option {
+	return func(i *Installer) { i.conflict = p }
+}
+
+// WithLock refuses archives and binaries that don't match l
+func WithLock(l *manifest.Lock) Option {
+	return func(i *Installer) { i.lock = l }
+}
+
+// WithSystem installs for all users with esc, which runs the final steps
+// as root, and configures PATH in /etc
+func WithSystem(esc installer.Escalator) Option {
+	return func(i *Installer) { i.esc = &esc }
+}
+
+// WithSkipPath leaves PATH alone
+func WithSkipPath() Option {
+	return func(i *Installer) { i.skipPath = true }
+}
+
+// WithDryRun makes Prepare only list archives and Install only report what
+// it would do, without writing anything
+func WithDryRun() Option {
+	return func(i *Installer) { i.dryRun = true }
+}
+
+// WithLockTimeout sets how long installing waits for another bii process
+// to finish. The default is a minute.
+func WithLockTimeout(d time.Duration) Option {
+	return func(i *Installer) { i.lockTimeout = d }
+}
+
+// WithVersionProbe runs every binary that is installed or inspected with
+// --version, version and -V to find its version, killing each attempt
+// after timeout, or version.DefaultTimeout if it is 0. Binaries built for
+// another platform aren't run, and none are where they can't be isolated;
+// the Installer then warns once. See version.Probe for how they are
+// isolated.
+func WithVersionProbe(timeout time.Duration) Option {
+	return func(i *Installer) {
+		i.probe = timeout
+		if i.probe <= 0 {
+			i.probe = version.DefaultTimeout
+		}
+	}
+}
+
+// New returns an Installer configured by opts
+func New(opts ...Option) (*Installer, error) {
+	i := &Installer{
+		prompter:    prompt.AssumeYes{},
+		conflict:    installer.ConflictOverwrite,
+		lockTimeout: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	
+	if i.logger == nil {
+		i.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	
+	if i.dest == "" {
+		if i.esc != nil {
+			i.dest = installer.SystemDestDir
+		} else {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get home directory: %w", err)
+			}
+			i.dest = filepath.Join(home, ".local", "bin")
+		}
+	}
+	
+	return i, nil
+}
+
+// Dest returns the directory binaries are installed to
+func (i *Installer) Dest() string {
+	return i.dest
+}
+
+// System reports whether binaries are installed for all users
+func (i *Installer) System() bool {
+	return i.esc != nil
+}
+
+// Shell returns the shell whose configuration is updated
+func (i *Installer) Shell() (string, error) {
+	if i.shell != "" {
+		return i.shell, nil
+	}
+	return shell.DetectShell()
+}
+
+// ctxReader fails reads once its context is done, so staging a large
+// archive stops when cancelled
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
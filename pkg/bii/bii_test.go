@@ -0,0 +1,384 @@
+package bii
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/flock"
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/prompt"
+	"github.com/repoleved08/bii/pkg/state"
+)
+
+// TestMain keeps the state file and the lock of the tests away from the
+// user's
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "bii-state-*")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_STATE_HOME", dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// tarGz returns a tar.gz archive holding files, which are executable if
+// they are in a bin directory
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		mode := int64(0644)
+		if filepath.Base(filepath.Dir(name)) == "bin" {
+			mode = 0755
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// declining answers no to every question
+type declining struct{}
+
+func (declining) Confirm(question string, defaultYes bool) (bool, error) {
+	return false, nil
+}
+
+func TestInstall(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	if err := os.WriteFile(archivePath, tarGz(t, map[string]string{"bin/tool": "v1", "README": "docs"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "bin")
+
+	inst, err := New(WithDest(dest), WithSkipPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := inst.Install(context.Background(), archivePath)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if result.Format != "tar.gz" {
+		t.Errorf("Expected tar.gz, got %s", result.Format)
+	}
+	if len(result.Installed) != 1 || result.Installed[0] != filepath.Join(dest, "tool") {
+		t.Errorf("Unexpected installed files %v", result.Installed)
+	}
+	if result.Path != nil {
+		t.Error("Expected PATH to be left alone")
+	}
+	if content, _ := os.ReadFile(filepath.Join(dest, "tool")); string(content) != "v1" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	// Nothing is left staged next to the destination
+	entries, _ := os.ReadDir(dest)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the binary in the destination, got %v", entries)
+	}
+
+	// The binary is recorded for later runs
+	statePath, err := state.DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := s.Binaries[filepath.Join(dest, "tool")]; !ok || b.Archive != archivePath || b.SHA256 == "" {
+		t.Errorf("Expected the binary to be recorded, got %+v", s.Binaries)
+	}
+}
+
+func TestInstallLock(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	if err := os.WriteFile(archivePath, tarGz(t, map[string]string{"bin/tool": "v1"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "bin")
+
+	inst, err := New(WithDest(dest), WithSkipPath(), WithLockTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process holds the lock
+	lockPath, err := state.LockPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := flock.TryLock(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background(), archivePath); !errors.Is(err, flock.ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "tool")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be installed without the lock")
+	}
+	other.Release()
+
+	// Holding the lock in this process doesn't keep Install out
+	release, err := inst.Lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background(), archivePath); err != nil {
+		t.Errorf("Install failed while the process held the lock: %v", err)
+	}
+	release()
+
+	// Once released, other processes can take it
+	other, err = flock.TryLock(lockPath)
+	if err != nil {
+		t.Fatalf("Expected the lock to be released, got %v", err)
+	}
+	other.Release()
+}
+
+func TestInstallFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dist/tool.bin": {Data: tarGz(t, map[string]string{"bin/tool": "from fs"})},
+	}
+	dest := t.TempDir()
+
+	inst, err := New(WithDest(dest), WithFS(fsys), WithSkipPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The format is sniffed, so the name doesn't matter
+	if _, err := inst.Install(context.Background(), "dist/tool.bin"); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dest, "tool")); string(content) != "from fs" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	if _, err := inst.Install(context.Background(), "dist/missing.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestInspectLogs(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	if err := os.WriteFile(archivePath, tarGz(t, map[string]string{"bin/tool": "v1", "README": "docs"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	inst, err := New(WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Inspect(context.Background(), archivePath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every entry is logged with why it is or isn't a binary
+	for _, want := range []string{
+		`entry=bin/tool binary=true reason="in a bin directory"`,
+		`entry=README binary=false reason="not executable and not in a bin directory"`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected the log to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestInstallVersionProbe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Probing shell scripts needs a unix shell")
+	}
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool_1.0.0_linux_amd64.tar.gz")
+	script := "#!/bin/sh\n[ \"$1\" = --version ] && echo \"tool v1.0.1\"\n"
+	if err := os.WriteFile(archivePath, tarGz(t, map[string]string{"bin/tool": script}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "bin")
+
+	inst, err := New(WithDest(dest), WithSkipPath(), WithVersionProbe(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inspection, err := inst.Inspect(context.Background(), archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspection.Version != "1.0.0" || inspection.Versions["bin/tool"] != "1.0.1" {
+		t.Errorf("Unexpected versions %q and %v", inspection.Version, inspection.Versions)
+	}
+
+	result, err := inst.Install(context.Background(), archivePath)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if result.Version != "1.0.0" {
+		t.Errorf("Expected the version from the file name, got %q", result.Version)
+	}
+	if v := result.Versions[filepath.Join(dest, "tool")]; v != "1.0.1" {
+		t.Errorf("Expected the probed version, got %q", v)
+	}
+}
+
+func TestInstallDryRun(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	if err := os.WriteFile(archivePath, tarGz(t, map[string]string{"bin/tool": "v1"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "bin")
+
+	inst, err := New(WithDest(dest), WithSkipPath(), WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := inst.Install(context.Background(), archivePath)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if len(result.Planned) != 1 || result.Planned[0].Status != installer.StatusNew {
+		t.Errorf("Unexpected plan %v", result.Planned)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("Expected a dry run not to create the destination")
+	}
+
+	p, err := inst.Prepare(context.Background(), archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if _, err := inst.Apply(context.Background(), p); !errors.Is(err, ErrDryRun) {
+		t.Errorf("Expected ErrDryRun, got %v", err)
+	}
+}
+
+func TestInstallErrors(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "bin")
+
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	if err := os.WriteFile(archivePath, tarGz(t, map[string]string{"bin/tool": "v1"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	corruptPath := filepath.Join(dir, "corrupt.tar.gz")
+	if err := os.WriteFile(corruptPath, []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	twicePath := filepath.Join(dir, "twice.tar.gz")
+	if err := os.WriteFile(twicePath, tarGz(t, map[string]string{"linux/bin/tool": "linux", "darwin/bin/tool": "darwin"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	docsPath := filepath.Join(dir, "docs.tar.gz")
+	if err := os.WriteFile(docsPath, tarGz(t, map[string]string{"README": "docs"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		ctx     context.Context
+		archive string
+		want    error
+	}{
+		{"missing", nil, context.Background(), filepath.Join(dir, "missing.tar.gz"), ErrNotFound},
+		{"corrupt", nil, context.Background(), corruptPath, archive.ErrCorrupt},
+		{"no binaries", nil, context.Background(), docsPath, ErrNoBinaries},
+		{"same name twice", nil, context.Background(), twicePath, installer.ErrConflict},
+		{"same name twice, skip", []Option{WithConflictPolicy(installer.ConflictSkip)}, context.Background(), twicePath, installer.ErrConflict},
+		{"declined", []Option{WithPrompter(declining{})}, context.Background(), archivePath, prompt.ErrDeclined},
+		{"cancelled", nil, cancelled, archivePath, context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst, err := New(append([]Option{WithDest(dest), WithSkipPath()}, tt.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := inst.Install(tt.ctx, tt.archive); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+
+			// Nothing is written to the destination before the installation
+			// was confirmed
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Errorf("Expected the destination not to be created, got %v", err)
+			}
+		})
+	}
+
+	// An existing file fails the installation under the fail policy
+	inst, err := New(WithDest(dest), WithSkipPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background(), archivePath); err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err = New(WithDest(dest), WithSkipPath(), WithConflictPolicy(installer.ConflictFail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background(), archivePath); !errors.Is(err, ErrRefused) || !errors.Is(err, installer.ErrExists) {
+		t.Errorf("Expected ErrRefused for an existing file, got %v", err)
+	}
+
+	// So is a directory in the way, whatever the policy
+	dirDest := filepath.Join(dir, "dirdest")
+	if err := os.MkdirAll(filepath.Join(dirDest, "tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	inst, err = New(WithDest(dirDest), WithSkipPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background(), archivePath); !errors.Is(err, ErrRefused) || !errors.Is(err, installer.ErrConflict) {
+		t.Errorf("Expected ErrRefused for a directory in the way, got %v", err)
+	}
+}
@@ -0,0 +1,589 @@
+package bii
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/installer"
+	"github.com/repoleved08/bii/pkg/manifest"
+	"github.com/repoleved08/bii/pkg/progress"
+	"github.com/repoleved08/bii/pkg/prompt"
+	"github.com/repoleved08/bii/pkg/state"
+	"github.com/repoleved08/bii/pkg/version"
+)
+
+// Inspection describes the contents of an archive
+type Inspection struct {
+	Archive  string
+	Format   string
+	// Version is the version in the archive's file name, if any
+	Version  string
+	Entries  []archive.Entry
+	Binaries []archive.Entry
+	// Versions are the versions the binaries print, by entry name, with
+	// WithVersionProbe
+	Versions map[string]string
+}
+
+// Plan is an archive prepared for installation. Unless on a dry run, its
+// binaries are staged in a temporary directory so Apply doesn't read the
+// archive again. Close removes whatever is left staged.
+type Plan struct {
+	Archive string
+	Format  string
+	// Version is the version in the archive's file name, if any
+	Version string
+	// SHA256 is the digest of the archive, unless it was only listed
+	SHA256  string
+	Dest    string
+	Entries []archive.Entry
+	// Binaries are the binaries Apply installs, after the conflict policy
+	Binaries []archive.Entry
+	// Skipped are existing files the conflict policy leaves alone
+	Skipped []string
+
+	staged *archive.Staged
+	dryRun bool
+	// source is where the archive came from, as recorded in the state file
+	source string
+}
+
+// Names returns the names of the plan's binaries in the archive
+func (p *Plan) Names() []string {
+	var names []string
+	for _, e := range p.Binaries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// Close removes the staged binaries that weren't installed
+func (p *Plan) Close() error {
+	if p.staged == nil {
+		return nil
+	}
+	return p.staged.Cleanup()
+}
+
+// Result is what installing an archive did
+type Result struct {
+	Archive string
+	Format  string
+	// Version is the version in the archive's file name, if any
+	Version string
+	Dest    string
+	DryRun  bool
+	// Installed are the paths of the installed binaries
+	Installed []string
+	// Versions are the versions the installed binaries print, by path, with
+	// WithVersionProbe
+	Versions map[string]string
+	// Planned are the files a dry run would write
+	Planned []installer.PlannedFile
+	Skipped []string
+	// Path is nil if PATH was left alone
+	Path     *PathResult
+	Warnings []string
+}
+
+// Inspect lists an archive and the binaries in it
+func (i *Installer) Inspect(ctx context.Context, name string) (*Inspection, error) {
+	if i.fsys != nil {
+		f, err := i.open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return i.InspectReader(ctx, name, f)
+	}
+	
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	
+	format, err := archive.FormatOf(name)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Binaries are only extracted to be asked for their version
+	if i.probe > 0 {
+		staged, err := archive.Stage(name, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect archive: %w", err)
+		}
+		defer staged.Cleanup()
+		return i.inspectStaged(ctx, name, staged), nil
+	}
+	
+	entries, err := archive.List(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect archive: %w", err)
+	}
+	
+	i.logEntries(name, entries)
+	return &Inspection{
+		Archive:  name,
+		Format:   format.Name(),
+		Version:  version.FromFilename(name),
+		Entries:  entries,
+		Binaries: archive.Binaries(entries),
+	}, nil
+}
+
+// InspectReader is Inspect for an archive read from r, whose format is
+// sniffed from its content. name is only used to describe it.
+func (i *Installer) InspectReader(ctx context.Context, name string, r io.Reader) (*Inspection, error) {
+	staged, err := archive.StageStream(ctxReader{ctx, r}, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect archive: %w", err)
+	}
+	defer staged.Cleanup()
+	
+	return i.inspectStaged(ctx, name, staged), nil
+}
+
+// inspectStaged describes a staged archive, probing its binaries for their
+// version with WithVersionProbe
+func (i *Installer) inspectStaged(ctx context.Context, name string, staged *archive.Staged) *Inspection {
+	i.logEntries(name, staged.Entries)
+	inspection := &Inspection{
+		Archive:  name,
+		Format:   staged.Format,
+		Version:  version.FromFilename(name),
+		Entries:  staged.Entries,
+		Binaries: archive.Binaries(staged.Entries),
+	}
+	
+	if i.probe > 0 {
+		inspection.Versions = make(map[string]string)
+		for _, e := range inspection.Binaries {
+			if path, ok := staged.Path(e.Name); ok {
+				if v := i.probeVersion(ctx, e, path); v != "" {
+					inspection.Versions[e.Name] = v
+				}
+			}
+		}
+	}
+	
+	return inspection
+}
+
+// Prepare reads an archive and decides what installing it does. Tar
+// archives are read only once: binaries are staged in a temporary
+// directory while listing, so Apply doesn't read the archive again.
+// Nothing is written to the destination before Apply.
+//
+// Errors found after the archive was read, such as ErrNoBinaries or
+// ErrRefused, are returned along with the Plan so callers can report what
+// the archive contains. A non-nil Plan must be closed.
+func (i *Installer) Prepare(ctx context.Context, name string) (*Plan, error) {
+	if i.fsys != nil {
+		f, err := i.open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return i.PrepareReader(ctx, name, f)
+	}
+	
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	
+	format, err := archive.FormatOf(name)
+	if err != nil {
+		return nil, err
+	}
+	p := &Plan{Archive: name, Format: format.Name(), Version: version.FromFilename(name), Dest: i.dest, dryRun: i.dryRun, source: name}
+	if abs, err := filepath.Abs(name); err == nil {
+		p.source = abs
+	}
+	
+	// Dry runs only list
+	if i.dryRun {
+		entries, err := archive.List(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect archive: %w", err)
+		}
+		return p, i.plan(ctx, p, entries)
+	}
+	
+	if _, ok := format.(archive.StreamFormat); ok {
+		var f *os.File
+		if f, err = os.Open(name); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		p.staged, err = archive.StageReader(ctxReader{ctx, f}, format.Name(), "", i.progress.ForArchive(name))
+	} else {
+		p.staged, err = archive.Stage(name, "", i.progress.ForArchive(name))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect archive: %w", err)
+	}
+	p.SHA256 = p.staged.SHA256
+	
+	return p, i.plan(ctx, p, p.staged.Entries)
+}
+
+// PrepareReader is Prepare for an archive read from r, such as stdin, whose
+// format is sniffed from its content. name is only used to describe it,
+// with "-" meaning stdin. Since r can only be read once, its binaries are
+// staged even on a dry run.
+func (i *Installer) PrepareReader(ctx context.Context, name string, r io.Reader) (*Plan, error) {
+	staged, err := archive.StageStream(ctxReader{ctx, r}, "", i.progress.ForArchive(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect archive: %w", err)
+	}
+	
+	p := &Plan{
+		Archive: name,
+		Format:  staged.Format,
+		Version: version.FromFilename(name),
+		SHA256:  staged.SHA256,
+		Dest:    i.dest,
+		staged:  staged,
+		dryRun:  i.dryRun,
+		source:  name,
+	}
+	if name == "-" {
+		p.source = "stdin"
+	}
+	return p, i.plan(ctx, p, staged.Entries)
+}
+
+// Apply installs the binaries of a plan and records them in the state
+// file, holding the lock. Failing to record them doesn't fail the
+// installation; it is reported in Result.Warnings.
+func (i *Installer) Apply(ctx context.Context, p *Plan) (*Result, error) {
+	if p.dryRun {
+		return nil, ErrDryRun
+	}
+	
+	result := &Result{Archive: p.Archive, Format: p.Format, Version: p.Version, Dest: p.Dest, Skipped: p.Skipped}
+	if len(p.Binaries) == 0 {
+		return result, nil
+	}
+	
+	release, err := i.Lock(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer release()
+	defer i.record(p, result)
+	
+	if i.esc != nil {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		installed, err := installer.InstallSystemStaged(p.staged, p.Dest, p.Names(), *i.esc)
+		result.Installed = installed
+		if err != nil {
+			return result, fmt.Errorf("installation failed: %w", err)
+		}
+	} else {
+		if err := os.MkdirAll(p.Dest, 0755); err != nil {
+			return result, fmt.Errorf("failed to create destination directory: %w", err)
+		}
+		for _, name := range p.Names() {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			installed, err := installer.InstallStaged(p.staged, p.Dest, []string{name})
+			result.Installed = append(result.Installed, installed...)
+			if err != nil {
+				return result, fmt.Errorf("installation failed: %w", err)
+			}
+		}
+	}
+	
+	for _, path := range result.Installed {
+		i.logger.Info("installed binary", "archive", p.Archive, "path", path)
+		i.progress.Emit(progress.Event{Kind: progress.Installed, Archive: p.Archive, Path: path})
+	}
+	
+	if i.probe > 0 {
+		entries := make(map[string]archive.Entry)
+		for _, e := range p.Binaries {
+			entries[filepath.Base(e.Name)] = e
+		}
+		
+		result.Versions = make(map[string]string)
+		for _, path := range result.Installed {
+			if v := i.probeVersion(ctx, entries[filepath.Base(path)], path); v != "" {
+				result.Versions[path] = v
+			}
+		}
+	}
+	
+	return result, nil
+}
+
+// Confirm asks the Prompter whether to install the binaries of plans. It
+// returns ErrCancelled if the answer is no, and ctx's error if ctx is done
+// before an answer comes.
+func (i *Installer) Confirm(ctx context.Context, plans ...*Plan) error {
+	count := 0
+	for _, p := range plans {
+		count += len(p.Binaries)
+	}
+	if count == 0 {
+		return nil
+	}
+	
+	question := fmt.Sprintf("Install %d binary(ies) to %s?", count, i.dest)
+	if len(plans) > 1 {
+		question = fmt.Sprintf("Install %d binary(ies) from %d archive(s) to %s?", count, len(plans), i.dest)
+	}
+	ok, err := prompt.ConfirmContext(ctx, i.prompter, question, true)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// Install prepares an archive, asks the Prompter to confirm, installs its
+// binaries and adds the destination to PATH, holding the lock from the
+// answer to the end. Failing to configure PATH doesn't fail the
+// installation; it is reported in Result.Path and Result.Warnings. On a dry
+// run, nothing is written and Result.Planned holds the files that would be.
+func (i *Installer) Install(ctx context.Context, name string) (*Result, error) {
+	p, err := i.Prepare(ctx, name)
+	if p != nil {
+		defer p.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	var result *Result
+	if i.dryRun {
+		result = &Result{Archive: p.Archive, Format: p.Format, Version: p.Version, Dest: p.Dest, DryRun: true, Skipped: p.Skipped}
+		if len(p.Binaries) > 0 {
+			if result.Planned, err = installer.Plan(p.Dest, p.Binaries); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if err := i.Confirm(ctx, p); err != nil {
+			return nil, err
+		}
+		
+		release, err := i.Lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		
+		if result, err = i.Apply(ctx, p); err != nil {
+			return result, err
+		}
+	}
+	
+	if i.skipPath || len(p.Binaries) == 0 {
+		return result, nil
+	}
+	
+	configure := i.ConfigurePath
+	if i.dryRun {
+		configure = i.PlanPath
+	}
+	path, err := configure(ctx)
+	if err != nil {
+		if path == nil {
+			path = &PathResult{}
+		}
+		path.Status = PathFailed
+		result.Warnings = append(result.Warnings, fmt.Sprintf("PATH configuration failed: %v", err))
+		i.logger.Warn("PATH configuration failed", "dest", i.dest, "error", err)
+	}
+	result.Path = path
+	
+	return result, nil
+}
+
+// open opens an archive in the Installer's filesystem
+func (i *Installer) open(name string) (fs.File, error) {
+	f, err := i.fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return f, err
+}
+
+// plan picks the binaries to install from the entries of an archive,
+// applying the conflict policy and checking the lock
+func (i *Installer) plan(ctx context.Context, p *Plan, entries []archive.Entry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.Entries = entries
+	i.logEntries(p.Archive, entries)
+	
+	binaries := archive.Binaries(entries)
+	i.logger.Debug("read archive", "archive", p.Archive, "format", p.Format, "entries", len(entries), "binaries", len(binaries))
+	if len(binaries) == 0 {
+		return ErrNoBinaries
+	}
+	
+	// Conflicts are refused under every policy, since one binary would
+	// silently replace another or the rename would fail half way
+	planned, err := installer.Plan(p.Dest, binaries)
+	if err != nil {
+		return err
+	}
+	install, skipped, err := installer.Resolve(planned, i.conflict)
+	if errors.Is(err, installer.ErrExists) {
+		return fmt.Errorf("%w: %w (conflict policy is %s)", ErrRefused, err, i.conflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRefused, err)
+	}
+	
+	binaries = nil
+	for _, f := range install {
+		binaries = append(binaries, f.Entry)
+	}
+	for _, f := range skipped {
+		p.Skipped = append(p.Skipped, f.Dest)
+		i.logger.Info("skipping existing file", "path", f.Dest)
+	}
+	p.Binaries = binaries
+	
+	if i.lock != nil && len(binaries) > 0 {
+		if err := i.verifyLocked(p); err != nil {
+			return fmt.Errorf("%w: %w", ErrRefused, err)
+		}
+		i.progress.Emit(progress.Event{Kind: progress.Verified, Archive: p.Archive})
+	}
+	
+	return nil
+}
+
+// stateMu keeps Applies running in parallel from losing each other's
+// changes to the state file
+var stateMu sync.Mutex
+
+// record adds the binaries installed from a plan to the state file, with
+// the version each printed or else the one in the archive name
+func (i *Installer) record(p *Plan, result *Result) {
+	if len(result.Installed) == 0 {
+		return
+	}
+	
+	warn := func(err error) {
+		i.logger.Warn("failed to record installed binaries", "error", err)
+		result.Warnings = append(result.Warnings, fmt.Sprintf("failed to record installed binaries: %v", err))
+	}
+	
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	
+	path, err := state.DefaultPath()
+	if err != nil {
+		warn(err)
+		return
+	}
+	s, err := state.Load(path)
+	if err != nil {
+		warn(err)
+		return
+	}
+	
+	now := time.Now().UTC()
+	for _, bin := range result.Installed {
+		v := result.Versions[bin]
+		if v == "" {
+			v = p.Version
+		}
+		s.Binaries[bin] = state.Binary{
+			Path:        bin,
+			Archive:     p.source,
+			SHA256:      p.SHA256,
+			Version:     v,
+			InstalledAt: now,
+		}
+	}
+	
+	if err := s.Save(path); err != nil {
+		warn(err)
+	}
+}
+
+// probeVersion asks a binary for its version. It returns "" for binaries
+// built for another platform and for those that print no version.
+func (i *Installer) probeVersion(ctx context.Context, e archive.Entry, path string) string {
+	if (e.OS != "" && e.OS != runtime.GOOS) || (e.Arch != "" && e.Arch != runtime.GOARCH) {
+		i.logger.Debug("not probing a binary built for another platform", "entry", e.Name, "os", e.OS, "arch", e.Arch)
+		return ""
+	}
+	
+	v, err := version.Probe(ctx, path, i.probe)
+	if errors.Is(err, version.ErrNoSandbox) {
+		i.noSandbox.Do(func() {
+			i.logger.Warn("not probing versions, binaries can't be run without network access here", "error", err)
+		})
+		return ""
+	}
+	if err != nil {
+		i.logger.Debug("version probe failed", "entry", e.Name, "error", err)
+		return ""
+	}
+	i.logger.Debug("probed version", "entry", e.Name, "version", v)
+	return v
+}
+
+// logEntries logs whether each entry of an archive is a binary, and why
+func (i *Installer) logEntries(name string, entries []archive.Entry) {
+	if !i.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	for _, e := range entries {
+		binary, reason := e.Classify()
+		i.logger.Debug("classified entry", "archive", name, "entry", e.Name, "binary", binary, "reason", reason)
+	}
+}
+
+// verifyLocked checks the archive and the binaries of a plan against the
+// lock, using the staged binaries if there are any
+func (i *Installer) verifyLocked(p *Plan) error {
+	var locked manifest.LockedTool
+	var err error
+	if p.staged != nil {
+		locked, err = installer.FindLockedDigest(i.lock, p.Archive, p.staged.SHA256)
+	} else {
+		locked, err = installer.FindLocked(i.lock, p.Archive)
+	}
+	if err != nil {
+		return err
+	}
+	
+	files := make(map[string]string)
+	for _, b := range p.Names() {
+		files[b] = filepath.Base(b)
+	}
+	if p.staged != nil {
+		return installer.VerifyStaged(p.staged, files, locked)
+	}
+	return installer.VerifyLocked(p.Archive, files, locked)
+}
@@ -0,0 +1,63 @@
+package bii
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/repoleved08/bii/pkg/flock"
+	"github.com/repoleved08/bii/pkg/state"
+)
+
+// processLock is the lock next to the state file while this process holds
+// it, shared by every Installer and goroutine in the process
+var processLock struct {
+	sync.Mutex
+	held  *flock.Lock
+	count int
+}
+
+// Lock takes the lock that keeps bii processes from changing destinations,
+// shell configuration and the state file at the same time, waiting up to
+// the lock timeout for another process to release it. Apply, Install and
+// ConfigurePath take it themselves; programs that change those files on
+// their own can hold it around their changes.
+//
+// The lock is held by the process, so taking it again while it is held
+// doesn't wait. It is released once every caller called release.
+func (i *Installer) Lock(ctx context.Context) (release func(), err error) {
+	processLock.Lock()
+	defer processLock.Unlock()
+
+	if processLock.count == 0 {
+		path, err := state.LockPath()
+		if err != nil {
+			return nil, err
+		}
+
+		l, err := flock.TryLock(path)
+		if errors.Is(err, flock.ErrLocked) {
+			i.logger.Warn("waiting for another bii process to finish", "timeout", i.lockTimeout)
+			l, err = flock.AcquireContext(ctx, path, i.lockTimeout)
+		}
+		if err != nil {
+			return nil, err
+		}
+		processLock.held = l
+	}
+	processLock.count++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			processLock.Lock()
+			defer processLock.Unlock()
+
+			processLock.count--
+			if processLock.count == 0 {
+				processLock.held.Release()
+				processLock.held = nil
+			}
+		})
+	}, nil
+}
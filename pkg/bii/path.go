@@ -0,0 +1,179 @@
+package bii
+
+import (
+	"context"
+
+	"github.com/repoleved08/bii/pkg/progress"
+	"github.com/repoleved08/bii/pkg/shell"
+)
+
+// PathStatus is the outcome of configuring PATH
+type PathStatus string
+
+const (
+	// PathUpdated means configuration files were changed
+	PathUpdated PathStatus = "updated"
+	// PathAlreadyConfigured means new shells already get the destination
+	PathAlreadyConfigured PathStatus = "already_configured"
+	// PathPlanned means a dry run would change configuration files
+	PathPlanned PathStatus = "planned"
+	// PathFailed means configuring PATH failed
+	PathFailed PathStatus = "failed"
+)
+
+// PathResult describes what configuring PATH did, or would do on a dry run
+type PathResult struct {
+	// Shell is empty for system installs, which configure /etc
+	Shell  string
+	Status PathStatus
+	// InCurrentPath reports whether the destination is in this process's
+	// PATH, even if no configuration file adds it
+	InCurrentPath bool
+	ChangedFiles  []string
+	// Diff holds the changes a dry run would make
+	Diff string
+}
+
+// ConfigurePath adds the destination to PATH: in the shell's configuration
+// files, or for all users in /etc on a system install. It holds the lock
+// while it does.
+func (i *Installer) ConfigurePath(ctx context.Context) (*PathResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	release, err := i.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	
+	if i.esc != nil {
+		return i.configureSystemPath()
+	}
+	
+	currentShell, err := i.Shell()
+	if err != nil {
+		return nil, err
+	}
+	result := &PathResult{Shell: currentShell}
+	
+	if result.InCurrentPath, err = shell.IsInPath(i.dest); err != nil {
+		return result, err
+	}
+	
+	// New shells only get the directory if the config files add it
+	configured, err := shell.IsConfigured(currentShell, i.dest)
+	if err != nil {
+		return result, err
+	}
+	if configured {
+		result.Status = PathAlreadyConfigured
+		return result, nil
+	}
+	
+	changed, err := shell.AddToPath(currentShell, i.dest)
+	if err != nil {
+		return result, err
+	}
+	
+	if len(changed) == 0 {
+		result.Status = PathAlreadyConfigured
+		return result, nil
+	}
+	
+	result.Status = PathUpdated
+	result.ChangedFiles = changed
+	i.logger.Info("added destination to PATH", "dest", i.dest, "shell", currentShell, "files", changed)
+	i.progress.Emit(progress.Event{Kind: progress.PathUpdated, Files: changed})
+	
+	return result, nil
+}
+
+// PlanPath returns the changes ConfigurePath would make, without making them
+func (i *Installer) PlanPath(ctx context.Context) (*PathResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	if i.esc != nil {
+		result := &PathResult{}
+		change, err := i.systemPathChange(result)
+		if err != nil || change == nil {
+			return result, err
+		}
+		
+		result.Status = PathPlanned
+		result.ChangedFiles = []string{change.Path}
+		result.Diff = change.Diff()
+		return result, nil
+	}
+	
+	currentShell, err := i.Shell()
+	if err != nil {
+		return nil, err
+	}
+	result := &PathResult{Shell: currentShell}
+	
+	configured, err := shell.IsConfigured(currentShell, i.dest)
+	if err != nil {
+		return result, err
+	}
+	if configured {
+		result.Status = PathAlreadyConfigured
+		return result, nil
+	}
+	
+	changes, err := shell.PlanAddToPath(currentShell, i.dest)
+	if err != nil {
+		return result, err
+	}
+	
+	result.Status = PathPlanned
+	for _, c := range changes {
+		result.ChangedFiles = append(result.ChangedFiles, c.Path)
+		result.Diff += c.Diff()
+	}
+	
+	return result, nil
+}
+
+// configureSystemPath adds the destination to PATH for all users
+func (i *Installer) configureSystemPath() (*PathResult, error) {
+	result := &PathResult{}
+	change, err := i.systemPathChange(result)
+	if err != nil || change == nil {
+		return result, err
+	}
+	
+	if err := i.esc.WriteFile(change.Path, []byte(change.After), 0644); err != nil {
+		return result, err
+	}
+	
+	result.Status = PathUpdated
+	result.ChangedFiles = []string{change.Path}
+	i.logger.Info("added destination to the system PATH", "dest", i.dest, "file", change.Path)
+	i.progress.Emit(progress.Event{Kind: progress.PathUpdated, Files: result.ChangedFiles})
+	
+	return result, nil
+}
+
+// systemPathChange returns the change to the system profile that puts the
+// destination on PATH, or nil if nothing needs to change
+func (i *Installer) systemPathChange(result *PathResult) (*shell.Change, error) {
+	// System directories such as /usr/local/bin are usually in the default PATH
+	if inPath, err := shell.IsInPath(i.dest); err == nil && inPath {
+		result.InCurrentPath = true
+		result.Status = PathAlreadyConfigured
+		return nil, nil
+	}
+	
+	change, err := shell.PlanAddToSystemPath(i.dest)
+	if err != nil {
+		return nil, err
+	}
+	if change == nil {
+		result.Status = PathAlreadyConfigured
+	}
+	return change, nil
+}
@@ -0,0 +1,94 @@
+// Package buildinfo describes the bii binary: its version, the commit it
+// was built from and when. Release builds set them with -ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/repoleved08/bii/pkg/buildinfo.Version=v0.2.0"
+//
+// and builds from go install fall back to what the Go toolchain records.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X at build time
+var (
+	// Version is the release, e.g. "v0.2.0"
+	Version string
+	// Commit is the full git commit hash
+	Commit string
+	// Date is when the binary was built, in RFC 3339
+	Date string
+)
+
+// devVersion is the version of builds that don't have one
+const devVersion = "dev"
+
+// readBuildInfo is debug.ReadBuildInfo, replaced in tests
+var readBuildInfo = debug.ReadBuildInfo
+
+// Info describes a bii binary
+type Info struct {
+	Version string `json:"version" yaml:"version"`
+	Commit  string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	// Date is the build date from -ldflags, or else the commit date
+	Date string `json:"date,omitempty" yaml:"date,omitempty"`
+	// Dirty reports a build from a tree with uncommitted changes
+	Dirty     bool   `json:"dirty,omitempty" yaml:"dirty,omitempty"`
+	GoVersion string `json:"go_version" yaml:"go_version"`
+	// Platform is the OS and architecture, e.g. "linux/amd64"
+	Platform string `json:"platform" yaml:"platform"`
+}
+
+// Get returns the build information of the running binary. Values set
+// with -ldflags win; the rest come from the module version and the version
+// control information the Go toolchain embeds.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := readBuildInfo(); ok {
+		// go install module@version records the version; local builds
+		// record "(devel)"
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		if bi.GoVersion != "" {
+			info.GoVersion = bi.GoVersion
+		}
+
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Dirty = s.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = devVersion
+	}
+	return info
+}
+
+// ShortCommit returns the first 12 characters of the commit, or "" if it
+// isn't known
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 12 {
+		return i.Commit[:12]
+	}
+	return i.Commit
+}
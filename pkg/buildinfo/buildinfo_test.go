@@ -0,0 +1,85 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+// withBuildInfo makes Get see bi and the given -ldflags values for the
+// rest of the test
+func withBuildInfo(t *testing.T, bi *debug.BuildInfo, version, commit, date string) {
+	t.Helper()
+
+	oldRead, oldVersion, oldCommit, oldDate := readBuildInfo, Version, Commit, Date
+	t.Cleanup(func() {
+		readBuildInfo, Version, Commit, Date = oldRead, oldVersion, oldCommit, oldDate
+	})
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return bi, bi != nil }
+	Version, Commit, Date = version, commit, date
+}
+
+func TestGet(t *testing.T) {
+	installed := &debug.BuildInfo{
+		GoVersion: "go1.22.1",
+		Main:      debug.Module{Path: "github.com/repoleved08/bii", Version: "v0.3.0"},
+	}
+	local := &debug.BuildInfo{
+		GoVersion: "go1.22.1",
+		Main:      debug.Module{Path: "github.com/repoleved08/bii", Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+			{Key: "vcs.time", Value: "2024-05-01T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	tests := []struct {
+		name                  string
+		bi                    *debug.BuildInfo
+		version, commit, date string
+		want                  Info
+	}{
+		{
+			"ldflags win",
+			local, "v0.2.0", "feedface", "2024-06-01T00:00:00Z",
+			Info{Version: "v0.2.0", Commit: "feedface", Date: "2024-06-01T00:00:00Z", Dirty: true, GoVersion: "go1.22.1"},
+		},
+		{
+			"go install",
+			installed, "", "", "",
+			Info{Version: "v0.3.0", GoVersion: "go1.22.1"},
+		},
+		{
+			"local build",
+			local, "", "", "",
+			Info{Version: "dev", Commit: "0123456789abcdef0123", Date: "2024-05-01T10:00:00Z", Dirty: true, GoVersion: "go1.22.1"},
+		},
+		{
+			"no build info",
+			nil, "", "", "",
+			Info{Version: "dev", GoVersion: runtime.Version()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withBuildInfo(t, tt.bi, tt.version, tt.commit, tt.date)
+
+			tt.want.Platform = runtime.GOOS + "/" + runtime.GOARCH
+			if got := Get(); got != tt.want {
+				t.Errorf("Get() = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortCommit(t *testing.T) {
+	if got := (Info{Commit: "0123456789abcdef0123"}).ShortCommit(); got != "0123456789ab" {
+		t.Errorf("Unexpected short commit %q", got)
+	}
+	if got := (Info{Commit: "abc"}).ShortCommit(); got != "abc" {
+		t.Errorf("Unexpected short commit %q", got)
+	}
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds defaults for bii's flags. Empty fields mean the built-in
+// default.
+type Config struct {
+	// Dest is the default install directory
+	Dest string `yaml:"dest,omitempty"`
+	// Shell is the shell to configure instead of the detected one
+	Shell string `yaml:"shell,omitempty"`
+	// Conflict is what install does with files that already exist:
+	// overwrite, skip or fail
+	Conflict string `yaml:"conflict,omitempty"`
+	// CacheDir is where downloaded archives are kept
+	CacheDir string `yaml:"cache_dir,omitempty"`
+	// Trust is "any", or "checksum" to refuse downloads without a sha256 in
+	// the manifest or lockfile
+	Trust string `yaml:"trust,omitempty"`
+	// Output is the default output format: text, json or yaml
+	Output    string `yaml:"output,omitempty"`
+	SkipPath  *bool  `yaml:"skip_path,omitempty"`
+	AssumeYes *bool  `yaml:"assume_yes,omitempty"`
+}
+
+// Source tells where a setting comes from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+)
+
+// key describes a setting that can be read and written by name
+type key struct {
+	name string
+	env  string
+	// values lists the allowed values, if restricted
+	values []string
+	field  func(c *Config) *string
+	flag   func(c *Config) **bool
+}
+
+var keys = []key{
+	{name: "dest", env: "BII_DEST", field: func(c *Config) *string { return &c.Dest }},
+	{name: "shell", env: "BII_SHELL", field: func(c *Config) *string { return &c.Shell }},
+	{name: "conflict", env: "BII_CONFLICT", values: []string{"overwrite", "skip", "fail"}, field: func(c *Config) *string { return &c.Conflict }},
+	{name: "cache_dir", env: "BII_CACHE_DIR", field: func(c *Config) *string { return &c.CacheDir }},
+	{name: "trust", env: "BII_TRUST", values: []string{"any", "checksum"}, field: func(c *Config) *string { return &c.Trust }},
+	{name: "output", env: "BII_OUTPUT", values: []string{"text", "json", "yaml"}, field: func(c *Config) *string { return &c.Output }},
+	{name: "skip_path", env: "BII_SKIP_PATH", flag: func(c *Config) **bool { return &c.SkipPath }},
+	{name: "assume_yes", flag: func(c *Config) **bool { return &c.AssumeYes }},
+}
+
+// Keys returns the names of all settings, in display order
+func Keys() []string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.name
+	}
+	return names
+}
+
+// Env returns the environment variable that overrides a setting, if any.
+// assume_yes has none here; prompts read BII_ASSUME_YES themselves.
+func Env(name string) string {
+	if k, err := lookup(name); err == nil {
+		return k.env
+	}
+	return ""
+}
+
+// Path returns the config file: $BII_CONFIG, or config.yaml in
+// $XDG_CONFIG_HOME/bii, defaulting to ~/.config/bii
+func Path() (string, error) {
+	if path := os.Getenv("BII_CONFIG"); path != "" {
+		return path, nil
+	}
+	
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "bii", "config.yaml"), nil
+	}
+	
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "bii", "config.yaml"), nil
+}
+
+// Load reads a config file. A missing file is an empty config.
+func Load(path string) (*Config, error) {
+	var c Config
+	
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	
+	for _, k := range keys {
+		if k.field == nil {
+			continue
+		}
+		if err := k.validate(*k.field(&c)); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+	
+	return &c, nil
+}
+
+// Save writes the config file
+func (c *Config) Save(path string) error {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// ApplyEnv overrides settings with the BII_* environment variables and
+// returns where each setting comes from
+func (c *Config) ApplyEnv() (map[string]Source, error) {
+	sources := make(map[string]Source)
+	
+	for _, k := range keys {
+		value, _ := c.Get(k.name)
+		if value != "" {
+			sources[k.name] = SourceFile
+		} else {
+			sources[k.name] = SourceDefault
+		}
+		
+		if k.env == "" {
+			continue
+		}
+		env, ok := os.LookupEnv(k.env)
+		if !ok || env == "" {
+			continue
+		}
+		if err := c.Set(k.name, env); err != nil {
+			return nil, fmt.Errorf("%s: %w", k.env, err)
+		}
+		sources[k.name] = SourceEnv
+	}
+	
+	return sources, nil
+}
+
+// Get returns a setting by name, or "" if it is unset
+func (c *Config) Get(name string) (string, error) {
+	k, err := lookup(name)
+	if err != nil {
+		return "", err
+	}
+	
+	if k.flag != nil {
+		if b := *k.flag(c); b != nil {
+			return strconv.FormatBool(*b), nil
+		}
+		return "", nil
+	}
+	return *k.field(c), nil
+}
+
+// Set changes a setting by name. An empty value unsets it.
+func (c *Config) Set(name, value string) error {
+	k, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	
+	if k.flag != nil {
+		if value == "" {
+			*k.flag(c) = nil
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", name, value)
+		}
+		*k.flag(c) = &b
+		return nil
+	}
+	
+	if err := k.validate(value); err != nil {
+		return err
+	}
+	*k.field(c) = value
+	return nil
+}
+
+func (k key) validate(value string) error {
+	if value == "" || len(k.values) == 0 {
+		return nil
+	}
+	for _, v := range k.values {
+		if v == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of %s, got %q", k.name, strings.Join(k.values, ", "), value)
+}
+
+func lookup(name string) (key, error) {
+	for _, k := range keys {
+		if k.name == name {
+			return k, nil
+		}
+	}
+	return key{}, fmt.Errorf("unknown setting: %s (known: %s)", name, strings.Join(Keys(), ", "))
+}
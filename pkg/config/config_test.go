@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every variable that overrides a setting
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, k := range keys {
+		if k.env != "" {
+			t.Setenv(k.env, "")
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BII_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	path, err := Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "bii", "config.yaml") {
+		t.Errorf("Unexpected path %s", path)
+	}
+
+	t.Setenv("BII_CONFIG", "/etc/bii.yaml")
+	if path, _ := Path(); path != "/etc/bii.yaml" {
+		t.Errorf("Expected BII_CONFIG to win, got %s", path)
+	}
+}
+
+func TestSetSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bii", "config.yaml")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load of missing file failed: %v", err)
+	}
+
+	for key, value := range map[string]string{
+		"dest":      "/opt/bin",
+		"conflict":  "skip",
+		"trust":     "checksum",
+		"skip_path": "true",
+	} {
+		if err := c.Set(key, value); err != nil {
+			t.Fatalf("Set(%s) failed: %v", key, err)
+		}
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Dest != "/opt/bin" || loaded.Conflict != "skip" || loaded.SkipPath == nil || !*loaded.SkipPath {
+		t.Errorf("Unexpected config after round trip: %+v", loaded)
+	}
+	if v, _ := loaded.Get("skip_path"); v != "true" {
+		t.Errorf("Expected skip_path true, got %q", v)
+	}
+	if v, _ := loaded.Get("assume_yes"); v != "" {
+		t.Errorf("Expected assume_yes unset, got %q", v)
+	}
+
+	if err := loaded.Set("dest", ""); err != nil || loaded.Dest != "" {
+		t.Errorf("Expected empty value to unset, got %q, %v", loaded.Dest, err)
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	var c Config
+
+	tests := []struct {
+		key, value, errText string
+	}{
+		{"conflict", "merge", "must be one of"},
+		{"output", "xml", "must be one of"},
+		{"skip_path", "maybe", "true or false"},
+		{"colour", "red", "unknown setting"},
+	}
+
+	for _, tt := range tests {
+		if err := c.Set(tt.key, tt.value); err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("Set(%s, %s): expected error containing %q, got %v", tt.key, tt.value, tt.errText, err)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	for _, content := range []string{"colour: red\n", "conflict: merge\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Expected error loading %q", content)
+		}
+	}
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("Expected empty file to load, got %v", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("BII_DEST", "/env/bin")
+	t.Setenv("BII_SKIP_PATH", "1")
+
+	c := Config{Dest: "/file/bin", Conflict: "fail"}
+	sources, err := c.ApplyEnv()
+	if err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+
+	if c.Dest != "/env/bin" || sources["dest"] != SourceEnv {
+		t.Errorf("Expected environment to override dest, got %s from %s", c.Dest, sources["dest"])
+	}
+	if c.Conflict != "fail" || sources["conflict"] != SourceFile {
+		t.Errorf("Expected conflict from file, got %s from %s", c.Conflict, sources["conflict"])
+	}
+	if sources["trust"] != SourceDefault {
+		t.Errorf("Expected trust to be default, got %s", sources["trust"])
+	}
+	if c.SkipPath == nil || !*c.SkipPath {
+		t.Error("Expected BII_SKIP_PATH to set skip_path")
+	}
+
+	t.Setenv("BII_CONFLICT", "merge")
+	if _, err := c.ApplyEnv(); err == nil || !strings.Contains(err.Error(), "BII_CONFLICT") {
+		t.Errorf("Expected invalid variable to be reported, got %v", err)
+	}
+}
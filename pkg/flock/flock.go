@@ -0,0 +1,80 @@
+// Package flock provides advisory file locks that keep bii processes from
+// changing the same files at the same time
+package flock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned when another process holds the lock
+var ErrLocked = errors.New("another bii process is running")
+
+// pollInterval is how often Acquire retries a held lock
+var pollInterval = 100 * time.Millisecond
+
+// Lock is a held lock. The operating system releases it if the process
+// exits without calling Release.
+type Lock struct {
+	f *os.File
+}
+
+// TryLock takes the lock on path without waiting, creating the file if
+// needed. It returns ErrLocked if another process holds it.
+func TryLock(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	
+	return &Lock{f: f}, nil
+}
+
+// Acquire takes the lock on path, waiting up to timeout for another process
+// to release it
+func Acquire(path string, timeout time.Duration) (*Lock, error) {
+	return AcquireContext(context.Background(), path, timeout)
+}
+
+// AcquireContext is Acquire that stops waiting when ctx is done
+func AcquireContext(ctx context.Context, path string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	
+	for {
+		l, err := TryLock(path)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w (waited %s for %s)", ErrLocked, timeout, path)
+		}
+		
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Release releases the lock
+func (l *Lock) Release() error {
+	if err := unlockFile(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,83 @@
+package flock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bii", "lock")
+
+	l, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	if _, err := TryLock(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked while held, got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := Acquire(path, 200*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked after timeout, got %v", err)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("Expected Acquire to wait for the timeout, returned after %s", waited)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+
+	l, err = TryLock(path)
+	if err != nil {
+		t.Fatalf("Expected lock to be free after release, got %v", err)
+	}
+	l.Release()
+}
+
+func TestAcquireWaits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+
+	l, err := TryLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		l.Release()
+	}()
+
+	waiting, err := Acquire(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expected lock once released, got %v", err)
+	}
+	waiting.Release()
+}
+
+func TestAcquireContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+
+	l, err := TryLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	if _, err := AcquireContext(ctx, path, 5*time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("Expected cancelling to stop the wait, returned after %s", waited)
+	}
+}
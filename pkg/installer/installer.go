@@ -1,14 +1,38 @@
 package installer
 
 import (
+	"errors"
 	"fmt"
 	"github.com/repoleved08/bii/pkg/archive"
 )
 
+var (
+	// ErrChecksumMismatch is returned when an archive or a binary doesn't
+	// have the expected SHA-256
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrLockMismatch is returned when what is installed isn't what the
+	// lockfile describes
+	ErrLockMismatch = errors.New("lockfile mismatch")
+	// ErrExists is returned when a file to install already exists and the
+	// conflict policy is ConflictFail
+	ErrExists = errors.New("file already exists")
+	// ErrConflict is returned when a binary can't be installed under any
+	// conflict policy, because its destination is a directory or another
+	// binary is installed to the same path
+	ErrConflict = errors.New("destination is a directory or shared by several binaries")
+	// ErrDownload is returned when an archive can't be downloaded
+	ErrDownload = errors.New("failed to download")
+	// ErrNeedsRoot is returned when a system install can't become root
+	ErrNeedsRoot = errors.New("installing system-wide needs root")
+	// ErrBinaryNotFound is returned when a binary the manifest or the
+	// lockfile names isn't in the archive
+	ErrBinaryNotFound = errors.New("binary not found in archive")
+)
+
 // Install extracts and installs binaries from an archive to the destination directory
 func Install(archivePath, destDir string, binaries []string) ([]string, error) {
 	if len(binaries) == 0 {
-		return nil, fmt.Errorf("no binaries to install")
+		return nil, archive.ErrNoBinaries
 	}
 	
 	installed, err := archive.Extract(archivePath, destDir, binaries)
@@ -18,3 +42,18 @@ func Install(archivePath, destDir string, binaries []string) ([]string, error) {
 	
 	return installed, nil
 }
+
+// InstallStaged installs binaries from an archive that was already staged,
+// moving them into place without reading the archive again
+func InstallStaged(staged *archive.Staged, destDir string, binaries []string) ([]string, error) {
+	if len(binaries) == 0 {
+		return nil, archive.ErrNoBinaries
+	}
+	
+	installed, err := staged.Install(destDir, binaries)
+	if err != nil {
+		return installed, fmt.Errorf("extraction failed: %w", err)
+	}
+	
+	return installed, nil
+}
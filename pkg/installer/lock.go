@@ -0,0 +1,193 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/manifest"
+)
+
+// LockTool resolves a manifest entry to the exact archive and binaries it
+// installs. With requireChecksum, downloads the manifest gives no sha256
+// for are refused rather than pinned to whatever was downloaded.
+func LockTool(m *manifest.Manifest, t manifest.Tool, cacheDir string, requireChecksum bool) (manifest.LockedTool, error) {
+	if requireChecksum {
+		if err := checkChecksum(t, t.SHA256); err != nil {
+			return manifest.LockedTool{}, err
+		}
+	}
+	
+	archivePath, err := Fetch(m.Source(t), t.SHA256, cacheDir)
+	if err != nil {
+		return manifest.LockedTool{}, err
+	}
+	
+	digest, err := FileSHA256(archivePath)
+	if err != nil {
+		return manifest.LockedTool{}, err
+	}
+	
+	entries, err := archive.List(archivePath)
+	if err != nil {
+		return manifest.LockedTool{}, fmt.Errorf("failed to inspect archive: %w", err)
+	}
+	
+	names, err := selectBinaries(t, entries)
+	if err != nil {
+		return manifest.LockedTool{}, err
+	}
+	
+	digests, err := EntryDigests(archivePath, names)
+	if err != nil {
+		return manifest.LockedTool{}, err
+	}
+	
+	locked := manifest.LockedTool{
+		Name:    t.Name,
+		Source:  t.Source,
+		Version: t.Version,
+		SHA256:  digest,
+	}
+	for entry, name := range names {
+		locked.Binaries = append(locked.Binaries, manifest.LockedBinary{
+			Entry:  entry,
+			Name:   name,
+			SHA256: digests[entry],
+		})
+	}
+	
+	return locked, nil
+}
+
+// EntryDigests returns the SHA-256 of each archive entry that is a key of
+// files, by extracting them to a temporary directory
+func EntryDigests(archivePath string, files map[string]string) (map[string]string, error) {
+	tmpDir, err := os.MkdirTemp("", "bii-digest-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	
+	// Number the files, since different entries may share a name
+	numbered := make(map[string]string)
+	entries := make(map[string]string)
+	i := 0
+	for entry := range files {
+		name := strconv.Itoa(i)
+		numbered[entry] = name
+		entries[name] = entry
+		i++
+	}
+	
+	extracted, err := archive.ExtractAs(archivePath, tmpDir, numbered)
+	if err != nil {
+		return nil, err
+	}
+	
+	digests := make(map[string]string)
+	for _, f := range extracted {
+		digest, err := FileSHA256(f)
+		if err != nil {
+			return nil, err
+		}
+		digests[entries[filepath.Base(f)]] = digest
+	}
+	
+	for entry := range files {
+		if _, ok := digests[entry]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrBinaryNotFound, entry)
+		}
+	}
+	
+	return digests, nil
+}
+
+// FindLocked returns the locked tool whose archive has the same digest as
+// archivePath. A tool locked to a different digest under the same file name
+// is reported as a mismatch.
+func FindLocked(l *manifest.Lock, archivePath string) (manifest.LockedTool, error) {
+	digest, err := FileSHA256(archivePath)
+	if err != nil {
+		return manifest.LockedTool{}, err
+	}
+	
+	return FindLockedDigest(l, archivePath, digest)
+}
+
+// FindLockedDigest is FindLocked for an archive whose digest is already
+// known, such as one read from stdin
+func FindLockedDigest(l *manifest.Lock, archivePath, digest string) (manifest.LockedTool, error) {
+	for _, t := range l.Tools {
+		if t.SHA256 == digest {
+			return t, nil
+		}
+	}
+	
+	for _, t := range l.Tools {
+		if path.Base(t.Source) == filepath.Base(archivePath) {
+			return manifest.LockedTool{}, fmt.Errorf("%w for %s: locked %s, got %s", ErrChecksumMismatch, archivePath, t.SHA256, digest)
+		}
+	}
+	
+	return manifest.LockedTool{}, fmt.Errorf("%w: %s is not in the lockfile", ErrLockMismatch, archivePath)
+}
+
+// VerifyLocked checks that every file to be installed from an archive is in
+// the lock with the same name and digest
+func VerifyLocked(archivePath string, files map[string]string, locked manifest.LockedTool) error {
+	if err := verifyDigest(archivePath, locked.SHA256); err != nil {
+		return err
+	}
+	
+	digests, err := EntryDigests(archivePath, files)
+	if err != nil {
+		return err
+	}
+	
+	return verifyBinaries(files, digests, locked)
+}
+
+// VerifyStaged is VerifyLocked for an archive that was already staged,
+// hashing the staged files instead of extracting them again
+func VerifyStaged(staged *archive.Staged, files map[string]string, locked manifest.LockedTool) error {
+	if staged.SHA256 != locked.SHA256 {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, locked.SHA256, staged.SHA256)
+	}
+	
+	digests := make(map[string]string)
+	for entry := range files {
+		path, ok := staged.Path(entry)
+		if !ok {
+			return fmt.Errorf("%s was not staged", entry)
+		}
+		digest, err := FileSHA256(path)
+		if err != nil {
+			return err
+		}
+		digests[entry] = digest
+	}
+	
+	return verifyBinaries(files, digests, locked)
+}
+
+// verifyBinaries compares the digests of files to be installed with the lock
+func verifyBinaries(files, digests map[string]string, locked manifest.LockedTool) error {
+	for entry, name := range files {
+		b, ok := locked.Binary(entry)
+		if !ok {
+			return fmt.Errorf("%w: %s is not locked for %s", ErrLockMismatch, entry, locked.Name)
+		}
+		if b.Name != name {
+			return fmt.Errorf("%w: %s is locked to be installed as %s, not %s", ErrLockMismatch, entry, b.Name, name)
+		}
+		if b.SHA256 != digests[entry] {
+			return fmt.Errorf("%w for %s: locked %s, got %s", ErrChecksumMismatch, entry, b.SHA256, digests[entry])
+		}
+	}
+	
+	return nil
+}
@@ -0,0 +1,102 @@
+package installer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/manifest"
+	"github.com/repoleved08/bii/pkg/state"
+)
+
+func TestLockAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	writeTarGz(t, archivePath, map[string]string{"bin/tool": "v1", "bin/other": "v1"})
+
+	m := loadManifest(t, dir, "tools:\n  - {name: tool, source: tool.tar.gz, binaries: [tool]}\n")
+	tool, _ := m.Tool("tool")
+
+	locked, err := LockTool(m, tool, filepath.Join(dir, "cache"), false)
+	if err != nil {
+		t.Fatalf("LockTool failed: %v", err)
+	}
+	if len(locked.Binaries) != 1 || locked.Binaries[0].Entry != "bin/tool" || locked.Binaries[0].Name != "tool" {
+		t.Fatalf("Unexpected locked binaries %+v", locked.Binaries)
+	}
+	lock := &manifest.Lock{Tools: []manifest.LockedTool{locked}}
+
+	found, err := FindLocked(lock, archivePath)
+	if err != nil || found.Name != "tool" {
+		t.Fatalf("FindLocked failed: %v", err)
+	}
+
+	if err := VerifyLocked(archivePath, map[string]string{"bin/tool": "tool"}, locked); err != nil {
+		t.Errorf("Expected locked binary to verify: %v", err)
+	}
+	if err := VerifyLocked(archivePath, map[string]string{"bin/other": "other"}, locked); err == nil {
+		t.Error("Expected unlocked binary to be refused")
+	}
+	if err := VerifyLocked(archivePath, map[string]string{"bin/tool": "renamed"}, locked); err == nil {
+		t.Error("Expected different name to be refused")
+	}
+
+	staged, err := archive.Stage(archivePath, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer staged.Cleanup()
+	if err := VerifyStaged(staged, map[string]string{"bin/tool": "tool"}, locked); err != nil {
+		t.Errorf("Expected staged binary to verify: %v", err)
+	}
+	if err := VerifyStaged(staged, map[string]string{"bin/other": "other"}, locked); err == nil {
+		t.Error("Expected unlocked staged binary to be refused")
+	}
+
+	// Same file name, different content
+	writeTarGz(t, archivePath, map[string]string{"bin/tool": "v2"})
+	if _, err := FindLocked(lock, archivePath); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected checksum mismatch, got %v", err)
+	}
+
+	other := filepath.Join(dir, "other.tar.gz")
+	writeTarGz(t, other, map[string]string{"bin/x": "x"})
+	if _, err := FindLocked(lock, other); !errors.Is(err, ErrLockMismatch) {
+		t.Errorf("Expected unlocked archive error, got %v", err)
+	}
+}
+
+func TestSyncFrozen(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	writeTarGz(t, archivePath, map[string]string{"bin/tool": "v1"})
+
+	m := loadManifest(t, dir, "tools:\n  - {name: tool, source: tool.tar.gz}\n")
+	tool, _ := m.Tool("tool")
+	opts := SyncOptions{DefaultDest: filepath.Join(dir, "bin"), CacheDir: filepath.Join(dir, "cache")}
+
+	locked, err := LockTool(m, tool, opts.CacheDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Lock = &manifest.Lock{Tools: []manifest.LockedTool{locked}}
+
+	// The archive changed after locking
+	writeTarGz(t, archivePath, map[string]string{"bin/tool": "v2"})
+	s := &state.State{Tools: make(map[string]state.Tool)}
+	if _, err := Sync(m, s, PlanSync(m, s, opts), opts); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected checksum mismatch, got %v", err)
+	}
+
+	writeTarGz(t, archivePath, map[string]string{"bin/tool": "v1"})
+	if _, err := Sync(m, s, PlanSync(m, s, opts), opts); err != nil {
+		t.Errorf("Expected locked archive to sync: %v", err)
+	}
+
+	// Tools missing from the lockfile are refused
+	m = loadManifest(t, dir, "tools:\n  - {name: tool, source: tool.tar.gz}\n  - {name: new, source: tool.tar.gz}\n")
+	if _, err := Sync(m, s, PlanSync(m, s, opts), opts); !errors.Is(err, ErrLockMismatch) {
+		t.Errorf("Expected unlocked tool to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/repoleved08/bii/pkg/archive"
+)
+
+// Status describes what installing a file does to its destination
+type Status string
+
+const (
+	// StatusNew means nothing exists at the destination yet
+	StatusNew Status = "new"
+	// StatusOverwrite means an existing file will be replaced
+	StatusOverwrite Status = "overwrite"
+	// StatusConflict means the file can't be installed as is, because the
+	// destination is a directory or another entry installs to the same path
+	StatusConflict Status = "conflict"
+)
+
+// PlannedFile is a binary Install would write
+type PlannedFile struct {
+	Entry  archive.Entry
+	Dest   string
+	Status Status
+}
+
+// Plan returns what installing binaries to destDir would do, without
+// writing anything
+func Plan(destDir string, binaries []archive.Entry) ([]PlannedFile, error) {
+	if len(binaries) == 0 {
+		return nil, archive.ErrNoBinaries
+	}
+	
+	if info, err := os.Stat(destDir); err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("destination is not a directory: %s", destDir)
+	}
+	
+	seen := make(map[string]int)
+	for _, b := range binaries {
+		seen[filepath.Base(b.Name)]++
+	}
+	
+	var planned []PlannedFile
+	for _, b := range binaries {
+		dest := filepath.Join(destDir, filepath.Base(b.Name))
+		
+		status := fileStatus(dest)
+		if seen[filepath.Base(b.Name)] > 1 {
+			status = StatusConflict
+		}
+		
+		planned = append(planned, PlannedFile{Entry: b, Dest: dest, Status: status})
+	}
+	
+	return planned, nil
+}
+
+// fileStatus returns what installing a file to dest does to it
+func fileStatus(dest string) Status {
+	info, err := os.Stat(dest)
+	switch {
+	case err != nil:
+		return StatusNew
+	case info.IsDir():
+		return StatusConflict
+	default:
+		return StatusOverwrite
+	}
+}
+
+// ConflictPolicy decides what Install does with files that already exist
+// at the destination
+type ConflictPolicy string
+
+const (
+	// ConflictOverwrite replaces existing files
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictSkip leaves existing files alone and installs the rest
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictFail refuses to install if any file exists
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ParseConflictPolicy parses a policy name; empty means overwrite
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch ConflictPolicy(s) {
+	case "":
+		return ConflictOverwrite, nil
+	case ConflictOverwrite, ConflictSkip, ConflictFail:
+		return ConflictPolicy(s), nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy: %s (use overwrite, skip or fail)", s)
+	}
+}
+
+// Resolve applies a conflict policy to planned files and returns the ones
+// to install and the ones skipped. Conflicts are refused under every
+// policy.
+func Resolve(planned []PlannedFile, policy ConflictPolicy) (install, skipped []PlannedFile, err error) {
+	for _, p := range planned {
+		switch {
+		case p.Status == StatusNew:
+			install = append(install, p)
+		case p.Status == StatusConflict:
+			return nil, nil, fmt.Errorf("%w: %s", ErrConflict, p.Dest)
+		case policy == ConflictFail:
+			return nil, nil, fmt.Errorf("%w: %s", ErrExists, p.Dest)
+		case policy == ConflictSkip:
+			skipped = append(skipped, p)
+		default:
+			install = append(install, p)
+		}
+	}
+	
+	return install, skipped, nil
+}
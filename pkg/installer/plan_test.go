@@ -0,0 +1,92 @@
+package installer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/repoleved08/bii/pkg/archive"
+)
+
+func TestPlan(t *testing.T) {
+	destDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(destDir, "existing"), []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(destDir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	binaries := []archive.Entry{
+		{Name: "app/bin/tool", Mode: 0755},
+		{Name: "app/bin/existing", Mode: 0755},
+		{Name: "app/bin/dir", Mode: 0755},
+		{Name: "app/linux/same", Mode: 0755},
+		{Name: "app/darwin/same", Mode: 0755},
+	}
+
+	planned, err := Plan(destDir, binaries)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	expected := []Status{StatusNew, StatusOverwrite, StatusConflict, StatusConflict, StatusConflict}
+	for i, p := range planned {
+		if p.Status != expected[i] {
+			t.Errorf("%s: expected %s, got %s", p.Entry.Name, expected[i], p.Status)
+		}
+		if p.Dest != filepath.Join(destDir, filepath.Base(p.Entry.Name)) {
+			t.Errorf("%s: unexpected destination %s", p.Entry.Name, p.Dest)
+		}
+	}
+
+	// Planning never creates the destination
+	missing := filepath.Join(destDir, "missing")
+	if _, err := Plan(missing, binaries[:1]); err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("Expected destination not to be created")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	planned := []PlannedFile{
+		{Dest: "/bin/new", Status: StatusNew},
+		{Dest: "/bin/existing", Status: StatusOverwrite},
+	}
+
+	install, skipped, err := Resolve(planned, ConflictOverwrite)
+	if err != nil || len(install) != 2 || len(skipped) != 0 {
+		t.Errorf("overwrite: expected everything to be installed, got %v, %v, %v", install, skipped, err)
+	}
+
+	install, skipped, err = Resolve(planned, ConflictSkip)
+	if err != nil || len(install) != 1 || len(skipped) != 1 || skipped[0].Dest != "/bin/existing" {
+		t.Errorf("skip: expected existing file to be skipped, got %v, %v, %v", install, skipped, err)
+	}
+
+	if _, _, err := Resolve(planned, ConflictFail); err == nil {
+		t.Error("fail: expected error for existing file")
+	}
+	if _, _, err := Resolve(planned[:1], ConflictFail); err != nil {
+		t.Errorf("fail: expected new files to install, got %v", err)
+	}
+
+	if _, err := ParseConflictPolicy("merge"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+	// Conflicts can't be skipped or overwritten
+	conflict := []PlannedFile{planned[0], {Dest: "/bin/dir", Status: StatusConflict}}
+	for _, policy := range []ConflictPolicy{ConflictOverwrite, ConflictSkip, ConflictFail} {
+		if _, _, err := Resolve(conflict, policy); !errors.Is(err, ErrConflict) {
+			t.Errorf("%s: expected conflict to be refused, got %v", policy, err)
+		}
+	}
+
+	if p, _ := ParseConflictPolicy(""); p != ConflictOverwrite {
+		t.Errorf("Expected overwrite by default, got %s", p)
+	}
+}
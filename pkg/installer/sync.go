@@ -0,0 +1,477 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/repoleved08/bii/pkg/archive"
+	"github.com/repoleved08/bii/pkg/manifest"
+	"github.com/repoleved08/bii/pkg/progress"
+	"github.com/repoleved08/bii/pkg/state"
+	"github.com/repoleved08/bii/pkg/version"
+)
+
+// ActionKind is what sync does to a tool
+type ActionKind string
+
+const (
+	ActionInstall   ActionKind = "install"
+	ActionUpgrade   ActionKind = "upgrade"
+	ActionRemove    ActionKind = "remove"
+	ActionUnchanged ActionKind = "unchanged"
+)
+
+// Action is a change sync makes to bring a tool in line with the manifest
+type Action struct {
+	Tool   string
+	Kind   ActionKind
+	Reason string
+	Dest   string
+	// Files are the files installed or removed. Sync keeps files a tool in
+	// the manifest installs, so it may remove fewer than were planned.
+	Files []string
+}
+
+// SyncOptions configure PlanSync and Sync
+type SyncOptions struct {
+	// DefaultDest is used for tools without a destination in the manifest
+	DefaultDest string
+	// Prune removes tools bii installed that the manifest no longer lists
+	Prune bool
+	// CacheDir is where downloaded archives are kept
+	CacheDir string
+	// Lock, when set, makes sync refuse archives and binaries whose
+	// digests differ from the lockfile
+	Lock *manifest.Lock
+	// RequireChecksum refuses downloads that neither the manifest nor the
+	// lockfile gives a sha256 for
+	RequireChecksum bool
+	// Conflict decides what happens to existing files the state doesn't
+	// record as installed by a tool; empty means overwrite
+	Conflict ConflictPolicy
+	// Progress, if set, is sent events as tools are downloaded, verified
+	// and installed. The archive of each event is the tool name.
+	Progress progress.Func
+	// Logger, if set, is told what sync decides and does
+	Logger *slog.Logger
+}
+
+// logger returns the logger of opts, which discards everything if unset
+func (opts SyncOptions) logger() *slog.Logger {
+	if opts.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return opts.Logger
+}
+
+// PlanSync compares the manifest with the installed tools and returns what
+// Sync would do, in manifest order followed by removals
+func PlanSync(m *manifest.Manifest, s *state.State, opts SyncOptions) []Action {
+	var actions []Action
+	
+	for _, t := range m.Tools {
+		dest := m.DestDir(t, opts.DefaultDest)
+		action := Action{Tool: t.Name, Dest: dest}
+		
+		installed, ok := s.Tools[t.Name]
+		switch {
+		case !ok:
+			action.Kind = ActionInstall
+			action.Reason = "not installed"
+		case installed.Spec != specOf(m, t, dest):
+			action.Kind = ActionUpgrade
+			action.Reason = upgradeReason(installed, m, t, dest)
+		case !filesExist(installed.Files):
+			action.Kind = ActionUpgrade
+			action.Reason = "installed files are missing"
+		default:
+			action.Kind = ActionUnchanged
+			action.Files = installed.Files
+		}
+		
+		actions = append(actions, action)
+	}
+	
+	if !opts.Prune {
+		return actions
+	}
+	
+	for _, name := range s.Names() {
+		if _, ok := m.Tool(name); ok {
+			continue
+		}
+		installed := s.Tools[name]
+		actions = append(actions, Action{
+			Tool:   name,
+			Kind:   ActionRemove,
+			Reason: "not in manifest",
+			Dest:   installed.Dest,
+			Files:  installed.Files,
+		})
+	}
+	
+	return actions
+}
+
+// Sync applies actions planned by PlanSync and records the result in s. It
+// stops at the first failing tool; tools synced before it stay recorded, so
+// the caller should save s even when an error is returned.
+//
+// A tool that would install a file another tool in the manifest installs
+// fails, and removing a tool keeps the files that tools in the manifest
+// now install, such as after a tool was renamed. Existing files no tool in
+// s installed are handled according to opts.Conflict. Binaries are staged
+// and moved into place, so a running binary can be replaced.
+func Sync(m *manifest.Manifest, s *state.State, actions []Action, opts SyncOptions) ([]Action, error) {
+	var done []Action
+	
+	// owners maps the files tools in the manifest install to the tool, as
+	// far as they are known before their archives are read
+	owners := make(map[string]string)
+	for _, a := range actions {
+		if a.Kind == ActionUnchanged {
+			for _, f := range a.Files {
+				owners[f] = a.Tool
+			}
+		}
+	}
+	
+	for _, a := range actions {
+		switch a.Kind {
+		case ActionInstall, ActionUpgrade:
+			t, _ := m.Tool(a.Tool)
+			installed, err := installTool(m, s, t, a.Dest, owners, opts)
+			if err != nil {
+				return done, fmt.Errorf("%s: %w", a.Tool, err)
+			}
+			a.Files = installed.Files
+			
+		case ActionRemove:
+			a.Files = unowned(a.Files, owners, opts.logger().With("tool", a.Tool))
+			if err := removeFiles(a.Files); err != nil {
+				return done, fmt.Errorf("%s: %w", a.Tool, err)
+			}
+			opts.logger().Info("removed tool", "tool", a.Tool, "files", a.Files)
+			delete(s.Tools, a.Tool)
+		}
+		
+		done = append(done, a)
+	}
+	
+	return done, nil
+}
+
+// installTool installs a tool and records it in s and owners
+func installTool(m *manifest.Manifest, s *state.State, t manifest.Tool, dest string, owners map[string]string, opts SyncOptions) (state.Tool, error) {
+	logger := opts.logger().With("tool", t.Name)
+	expected := t.SHA256
+	var locked manifest.LockedTool
+	if opts.Lock != nil {
+		var err error
+		if locked, err = lockedTool(opts.Lock, t); err != nil {
+			return state.Tool{}, err
+		}
+		expected = locked.SHA256
+	}
+	if opts.RequireChecksum {
+		if err := checkChecksum(t, expected); err != nil {
+			return state.Tool{}, err
+		}
+	}
+	
+	fn := opts.Progress.ForArchive(t.Name)
+	path, err := fetch(m.Source(t), expected, opts.CacheDir, fn)
+	if err != nil {
+		return state.Tool{}, err
+	}
+	
+	digest, err := FileSHA256(path)
+	if err != nil {
+		return state.Tool{}, err
+	}
+	
+	entries, err := archive.List(path)
+	if err != nil {
+		return state.Tool{}, fmt.Errorf("failed to inspect archive: %w", err)
+	}
+	for _, e := range entries {
+		binary, reason := e.Classify()
+		logger.Debug("classified entry", "entry", e.Name, "binary", binary, "reason", reason)
+	}
+	
+	names, err := selectBinaries(t, entries)
+	if err != nil {
+		return state.Tool{}, err
+	}
+	if err := resolveTargets(s, t, dest, names, owners, opts.Conflict, logger); err != nil {
+		return state.Tool{}, err
+	}
+	
+	var selected []string
+	for entry := range names {
+		selected = append(selected, entry)
+	}
+	staged, err := archive.StageEntries(path, "", selected, fn)
+	if err != nil {
+		return state.Tool{}, fmt.Errorf("extraction failed: %w", err)
+	}
+	defer staged.Cleanup()
+	
+	if opts.Lock != nil {
+		if err := VerifyStaged(staged, names, locked); err != nil {
+			return state.Tool{}, err
+		}
+		fn.Emit(progress.Event{Kind: progress.Verified})
+	}
+	
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return state.Tool{}, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	
+	files, err := staged.InstallAs(dest, names)
+	if err != nil {
+		return state.Tool{}, fmt.Errorf("installation failed: %w", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		owners[f] = t.Name
+		logger.Info("installed binary", "path", f)
+		fn.Emit(progress.Event{Kind: progress.Installed, Path: f})
+	}
+	
+	// Files from the previous install that this one no longer provides
+	if previous, ok := s.Tools[t.Name]; ok {
+		var stale []string
+		for _, f := range previous.Files {
+			if !contains(files, f) {
+				stale = append(stale, f)
+			}
+		}
+		stale = unowned(stale, owners, logger)
+		if err := removeFiles(stale); err != nil {
+			return state.Tool{}, err
+		}
+		if len(stale) > 0 {
+			logger.Info("removed files the new version no longer provides", "files", stale)
+		}
+	}
+	
+	installed := state.Tool{
+		Name:        t.Name,
+		Source:      t.Source,
+		Version:     toolVersion(m, t),
+		SHA256:      digest,
+		Dest:        dest,
+		Files:       files,
+		Spec:        specOf(m, t, dest),
+		InstalledAt: time.Now().UTC(),
+	}
+	s.Tools[t.Name] = installed
+	
+	return installed, nil
+}
+
+// resolveTargets refuses files another tool in the manifest installs and
+// applies the conflict policy to existing files no tool in s installed,
+// removing the ones it skips from names
+func resolveTargets(s *state.State, t manifest.Tool, dest string, names map[string]string, owners map[string]string, policy ConflictPolicy, logger *slog.Logger) error {
+	recorded := make(map[string]bool)
+	for _, installed := range s.Tools {
+		for _, f := range installed.Files {
+			recorded[f] = true
+		}
+	}
+	
+	var entries []string
+	for entry := range names {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	
+	var planned []PlannedFile
+	for _, entry := range entries {
+		f := filepath.Join(dest, names[entry])
+		if owner, ok := owners[f]; ok && owner != t.Name {
+			return fmt.Errorf("%w: %s is also installed by %s", ErrConflict, f, owner)
+		}
+		
+		status := fileStatus(f)
+		if recorded[f] && status == StatusOverwrite {
+			continue
+		}
+		planned = append(planned, PlannedFile{Entry: archive.Entry{Name: entry}, Dest: f, Status: status})
+	}
+	
+	_, skipped, err := Resolve(planned, policy)
+	if err != nil {
+		return err
+	}
+	for _, p := range skipped {
+		logger.Warn("skipping a file bii didn't install", "path", p.Dest)
+		delete(names, p.Entry.Name)
+	}
+	return nil
+}
+
+// toolVersion returns the version of a manifest entry, or the version in the
+// name of its archive if the manifest gives none
+func toolVersion(m *manifest.Manifest, t manifest.Tool) string {
+	if t.Version != "" {
+		return t.Version
+	}
+	return version.FromFilename(m.Source(t))
+}
+
+// checkChecksum refuses to download a tool without a sha256 to verify it
+// against, for the "checksum" trust policy
+func checkChecksum(t manifest.Tool, expected string) error {
+	if expected == "" && manifest.IsURL(t.Source) {
+		return fmt.Errorf("no sha256 to verify the download against")
+	}
+	return nil
+}
+
+// lockedTool returns the lock entry for a manifest entry, which must still
+// describe the same archive
+func lockedTool(l *manifest.Lock, t manifest.Tool) (manifest.LockedTool, error) {
+	locked, ok := l.Tool(t.Name)
+	if !ok {
+		return locked, fmt.Errorf("%w: not in the lockfile, run bii lock", ErrLockMismatch)
+	}
+	if locked.Source != t.Source {
+		return locked, fmt.Errorf("%w: source changed since the lockfile was written, run bii lock", ErrLockMismatch)
+	}
+	if t.SHA256 != "" && t.SHA256 != locked.SHA256 {
+		return locked, fmt.Errorf("%w: sha256 in the manifest differs from the lockfile, run bii lock", ErrLockMismatch)
+	}
+	return locked, nil
+}
+
+// selectBinaries picks the entries a tool installs, matched by full name or
+// base name, and maps each to the name it is installed as
+func selectBinaries(t manifest.Tool, entries []archive.Entry) (map[string]string, error) {
+	var selected []archive.Entry
+	
+	if len(t.Binaries) == 0 {
+		selected = archive.Binaries(entries)
+		if len(selected) == 0 {
+			return nil, archive.ErrNoBinaries
+		}
+	}
+	
+	for _, name := range t.Binaries {
+		found := false
+		for _, e := range entries {
+			if e.Type() == "file" && (e.Name == name || filepath.Base(e.Name) == name) {
+				selected = append(selected, e)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrBinaryNotFound, name)
+		}
+	}
+	
+	names := make(map[string]string)
+	targets := make(map[string]string)
+	for _, e := range selected {
+		base := filepath.Base(e.Name)
+		target := base
+		if renamed, ok := t.Rename[base]; ok {
+			target = renamed
+		}
+		
+		if other, ok := targets[target]; ok && other != e.Name {
+			return nil, fmt.Errorf("%s and %s would both be installed as %s", other, e.Name, target)
+		}
+		targets[target] = e.Name
+		names[e.Name] = target
+	}
+	
+	for from := range t.Rename {
+		found := false
+		for _, e := range selected {
+			found = found || filepath.Base(e.Name) == from
+		}
+		if !found {
+			return nil, fmt.Errorf("cannot rename %s: not a selected binary", from)
+		}
+	}
+	
+	return names, nil
+}
+
+// specOf fingerprints everything in a manifest entry that affects what gets
+// installed, so that a change to any of it triggers an upgrade
+func specOf(m *manifest.Manifest, t manifest.Tool, dest string) string {
+	t.Source = m.Source(t)
+	t.Dest = dest
+	
+	content, _ := json.Marshal(t)
+	return sha256Hex(content)
+}
+
+func upgradeReason(installed state.Tool, m *manifest.Manifest, t manifest.Tool, dest string) string {
+	v := toolVersion(m, t)
+	switch {
+	case installed.Version != v && v != "":
+		if installed.Version == "" {
+			return fmt.Sprintf("version changed to %s", v)
+		}
+		return fmt.Sprintf("version %s → %s", installed.Version, v)
+	case installed.Dest != dest:
+		return fmt.Sprintf("destination changed to %s", dest)
+	case installed.Source != t.Source:
+		return "source changed"
+	default:
+		return "manifest entry changed"
+	}
+}
+
+func filesExist(files []string) bool {
+	for _, f := range files {
+		if _, err := os.Stat(f); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// unowned returns the files no tool in owners installs, logging the ones
+// that are kept
+func unowned(files []string, owners map[string]string, logger *slog.Logger) []string {
+	var result []string
+	for _, f := range files {
+		if owner, ok := owners[f]; ok {
+			logger.Info("keeping a file another tool installs", "path", f, "owner", owner)
+			continue
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+func removeFiles(files []string) error {
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,424 @@
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/repoleved08/bii/pkg/manifest"
+	"github.com/repoleved08/bii/pkg/state"
+)
+
+// writeTarGz creates a tar.gz archive with the given executable files
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadManifest(t *testing.T, dir, content string) *manifest.Manifest {
+	t.Helper()
+
+	path := filepath.Join(dir, manifest.FileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := manifest.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func kinds(actions []Action) map[string]ActionKind {
+	result := make(map[string]ActionKind)
+	for _, a := range actions {
+		result[a.Tool] = a.Kind
+	}
+	return result
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "bin")
+	opts := SyncOptions{DefaultDest: dest, CacheDir: filepath.Join(dir, "cache")}
+
+	writeTarGz(t, filepath.Join(dir, "one.tar.gz"), map[string]string{"one/bin/one": "v1"})
+	writeTarGz(t, filepath.Join(dir, "two.tar.gz"), map[string]string{
+		"two/bin/two":    "v1",
+		"two/bin/helper": "v1",
+	})
+
+	m := loadManifest(t, dir, `tools:
+  - name: one
+    source: one.tar.gz
+    version: "1.0"
+  - name: two
+    source: two.tar.gz
+    binaries: [two]
+    rename:
+      two: second
+`)
+	s := &state.State{Tools: make(map[string]state.Tool)}
+
+	actions := PlanSync(m, s, opts)
+	if k := kinds(actions); k["one"] != ActionInstall || k["two"] != ActionInstall {
+		t.Fatalf("Expected both tools to be installed, got %v", k)
+	}
+
+	if _, err := Sync(m, s, actions, opts); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "one")); err != nil {
+		t.Errorf("Expected one to be installed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "second")); err != nil {
+		t.Errorf("Expected two to be installed as second: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "helper")); !os.IsNotExist(err) {
+		t.Error("Expected unselected binary not to be installed")
+	}
+
+	// Nothing to do on a second run
+	actions = PlanSync(m, s, opts)
+	if k := kinds(actions); k["one"] != ActionUnchanged || k["two"] != ActionUnchanged {
+		t.Fatalf("Expected tools to be unchanged, got %v", k)
+	}
+
+	// A new version replaces the binary and a dropped tool is pruned
+	writeTarGz(t, filepath.Join(dir, "one.tar.gz"), map[string]string{"one/bin/one-new": "v2"})
+	m = loadManifest(t, dir, `tools:
+  - name: one
+    source: one.tar.gz
+    version: "2.0"
+`)
+	opts.Prune = true
+
+	actions = PlanSync(m, s, opts)
+	if k := kinds(actions); k["one"] != ActionUpgrade || k["two"] != ActionRemove {
+		t.Fatalf("Expected upgrade and removal, got %v", k)
+	}
+	if actions[0].Reason != "version 1.0 → 2.0" {
+		t.Errorf("Unexpected upgrade reason %q", actions[0].Reason)
+	}
+
+	if _, err := Sync(m, s, actions, opts); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "one-new")); err != nil {
+		t.Errorf("Expected upgraded binary: %v", err)
+	}
+	for _, stale := range []string{"one", "second"} {
+		if _, err := os.Stat(filepath.Join(dest, stale)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", stale)
+		}
+	}
+	if _, ok := s.Tools["two"]; ok {
+		t.Error("Expected removed tool to be dropped from state")
+	}
+
+	// Deleted files are reinstalled
+	os.Remove(filepath.Join(dest, "one-new"))
+	if k := kinds(PlanSync(m, s, opts)); k["one"] != ActionUpgrade {
+		t.Errorf("Expected missing files to trigger an upgrade, got %v", k)
+	}
+}
+
+func TestSyncPruneRenamed(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "bin")
+	opts := SyncOptions{DefaultDest: dest, CacheDir: filepath.Join(dir, "cache"), Prune: true}
+	writeTarGz(t, filepath.Join(dir, "kubectl.tar.gz"), map[string]string{"bin/kubectl": "v1"})
+	writeTarGz(t, filepath.Join(dir, "tools.tar.gz"), map[string]string{"bin/kubectl": "v2", "bin/helm": "v2"})
+
+	m := loadManifest(t, dir, "tools:\n  - {name: kubectl, source: kubectl.tar.gz}\n")
+	s := &state.State{Tools: make(map[string]state.Tool)}
+	if _, err := Sync(m, s, PlanSync(m, s, opts), opts); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Pruning the old name keeps the file the new name installs
+	m = loadManifest(t, dir, "tools:\n  - {name: kube, source: kubectl.tar.gz}\n")
+	actions, err := Sync(m, s, PlanSync(m, s, opts), opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if k := kinds(actions); k["kube"] != ActionInstall || k["kubectl"] != ActionRemove {
+		t.Fatalf("Expected install and removal, got %v", k)
+	}
+	if len(actions[1].Files) != 0 {
+		t.Errorf("Expected nothing to be removed, got %v", actions[1].Files)
+	}
+	kubectl := filepath.Join(dest, "kubectl")
+	if content, err := os.ReadFile(kubectl); err != nil || string(content) != "v1" {
+		t.Errorf("Expected kubectl to stay installed, got %q, %v", content, err)
+	}
+	if _, ok := s.Tools["kubectl"]; ok {
+		t.Error("Expected the old name to be dropped from state")
+	}
+
+	// So does a tool that takes over a file of a tool that is pruned
+	m = loadManifest(t, dir, "tools:\n  - {name: tools, source: tools.tar.gz}\n")
+	if _, err := Sync(m, s, PlanSync(m, s, opts), opts); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if content, err := os.ReadFile(kubectl); err != nil || string(content) != "v2" {
+		t.Errorf("Expected kubectl from the new tool, got %q, %v", content, err)
+	}
+	if files := s.Tools["tools"].Files; len(files) != 2 || !filesExist(files) {
+		t.Errorf("Expected the recorded files to exist, got %v", files)
+	}
+
+	// Two tools can't install the same file
+	m = loadManifest(t, dir, "tools:\n  - {name: tools, source: tools.tar.gz}\n  - {name: kubectl, source: kubectl.tar.gz}\n")
+	if _, err := Sync(m, s, PlanSync(m, s, opts), opts); err == nil || !strings.Contains(err.Error(), "also installed by tools") {
+		t.Errorf("Expected a shared file to be refused, got %v", err)
+	}
+	if content, _ := os.ReadFile(kubectl); string(content) != "v2" {
+		t.Errorf("Expected kubectl to be left alone, got %q", content)
+	}
+}
+
+func TestSyncConflict(t *testing.T) {
+	dir := t.TempDir()
+	writeTarGz(t, filepath.Join(dir, "v1.tar.gz"), map[string]string{"bin/tool": "v1", "bin/other": "v1"})
+	writeTarGz(t, filepath.Join(dir, "v2.tar.gz"), map[string]string{"bin/tool": "v2", "bin/other": "v2"})
+
+	tests := []struct {
+		name   string
+		policy ConflictPolicy
+		dir    bool
+		err    error
+		// content is what the existing file holds afterwards
+		content string
+	}{
+		{"overwrite", ConflictOverwrite, false, nil, "v1"},
+		{"default", "", false, nil, "v1"},
+		{"skip", ConflictSkip, false, nil, "mine"},
+		{"fail", ConflictFail, false, ErrExists, "mine"},
+		{"directory", ConflictOverwrite, true, ErrConflict, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			opts := SyncOptions{DefaultDest: dest, CacheDir: filepath.Join(dir, "cache"), Conflict: tt.policy}
+			existing := filepath.Join(dest, "tool")
+			if tt.dir {
+				if err := os.Mkdir(existing, 0755); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := os.WriteFile(existing, []byte("mine"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			m := loadManifest(t, dir, "tools:\n  - {name: t, source: v1.tar.gz}\n")
+			s := &state.State{Tools: make(map[string]state.Tool)}
+			_, err := Sync(m, s, PlanSync(m, s, opts), opts)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Expected %v, got %v", tt.err, err)
+				}
+				if _, err := os.Stat(filepath.Join(dest, "other")); !os.IsNotExist(err) {
+					t.Error("Expected nothing to be installed")
+				}
+				if len(s.Tools) != 0 {
+					t.Errorf("Expected nothing to be recorded, got %v", s.Tools)
+				}
+			} else if err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if tt.dir {
+				return
+			}
+
+			if content, _ := os.ReadFile(existing); string(content) != tt.content {
+				t.Errorf("Expected %q, got %q", tt.content, content)
+			}
+			if tt.err != nil {
+				return
+			}
+			recorded := contains(s.Tools["t"].Files, existing)
+			if recorded != (tt.content == "v1") {
+				t.Errorf("Expected %s to be recorded only if installed, got %v", existing, s.Tools["t"].Files)
+			}
+
+			// Files the tool installed are upgraded under every policy
+			m = loadManifest(t, dir, "tools:\n  - {name: t, source: v2.tar.gz}\n")
+			if _, err := Sync(m, s, PlanSync(m, s, opts), opts); err != nil {
+				t.Fatalf("Upgrade failed: %v", err)
+			}
+			if content, _ := os.ReadFile(filepath.Join(dest, "other")); string(content) != "v2" {
+				t.Errorf("Expected other to be upgraded, got %q", content)
+			}
+		})
+	}
+}
+
+func TestSyncVersionFromFilename(t *testing.T) {
+	dir := t.TempDir()
+	opts := SyncOptions{DefaultDest: filepath.Join(dir, "bin"), CacheDir: filepath.Join(dir, "cache")}
+	writeTarGz(t, filepath.Join(dir, "tool_1.4.0_linux_amd64.tar.gz"), map[string]string{"bin/tool": "v1"})
+	writeTarGz(t, filepath.Join(dir, "tool_1.5.0_linux_amd64.tar.gz"), map[string]string{"bin/tool": "v2"})
+
+	// Without a version in the manifest, the one in the archive name is kept
+	m := loadManifest(t, dir, "tools:\n  - {name: t, source: tool_1.4.0_linux_amd64.tar.gz}\n")
+	s := &state.State{Tools: make(map[string]state.Tool)}
+	if _, err := Sync(m, s, PlanSync(m, s, opts), opts); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if v := s.Tools["t"].Version; v != "1.4.0" {
+		t.Errorf("Expected version 1.4.0, got %q", v)
+	}
+
+	m = loadManifest(t, dir, "tools:\n  - {name: t, source: tool_1.5.0_linux_amd64.tar.gz}\n")
+	actions := PlanSync(m, s, opts)
+	if actions[0].Kind != ActionUpgrade || actions[0].Reason != "version 1.4.0 → 1.5.0" {
+		t.Errorf("Unexpected action %+v", actions[0])
+	}
+}
+
+func TestSyncUppercaseChecksum(t *testing.T) {
+	dir := t.TempDir()
+	opts := SyncOptions{DefaultDest: filepath.Join(dir, "bin"), CacheDir: filepath.Join(dir, "cache")}
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	writeTarGz(t, archivePath, map[string]string{"bin/tool": "x"})
+
+	digest, err := FileSHA256(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := loadManifest(t, dir, "tools:\n  - {name: t, source: tool.tar.gz, sha256: "+strings.ToUpper(digest)+"}\n")
+	s := &state.State{Tools: make(map[string]state.Tool)}
+
+	if _, err := Sync(m, s, PlanSync(m, s, opts), opts); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+}
+
+func TestSyncErrors(t *testing.T) {
+	dir := t.TempDir()
+	opts := SyncOptions{DefaultDest: filepath.Join(dir, "bin"), CacheDir: filepath.Join(dir, "cache")}
+	writeTarGz(t, filepath.Join(dir, "tool.tar.gz"), map[string]string{"bin/tool": "x"})
+
+	tests := []struct {
+		name     string
+		manifest string
+		errText  string
+	}{
+		{"Checksum", "tools:\n  - {name: t, source: tool.tar.gz, sha256: " + strings.Repeat("0", 64) + "}\n", "checksum mismatch"},
+		{"Missing binary", "tools:\n  - {name: t, source: tool.tar.gz, binaries: [other]}\n", "not found in archive"},
+		{"Bad rename", "tools:\n  - {name: t, source: tool.tar.gz, rename: {other: x}}\n", "not a selected binary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := loadManifest(t, dir, tt.manifest)
+			s := &state.State{Tools: make(map[string]state.Tool)}
+
+			_, err := Sync(m, s, PlanSync(m, s, opts), opts)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+			}
+			if len(s.Tools) != 0 {
+				t.Errorf("Expected nothing to be recorded, got %v", s.Tools)
+			}
+		})
+	}
+}
+
+func TestSyncRequireChecksum(t *testing.T) {
+	dir := t.TempDir()
+	opts := SyncOptions{DefaultDest: filepath.Join(dir, "bin"), CacheDir: filepath.Join(dir, "cache"), RequireChecksum: true}
+
+	m := loadManifest(t, dir, "tools:\n  - {name: t, source: 'https://example.com/tool.tar.gz'}\n")
+	s := &state.State{Tools: make(map[string]state.Tool)}
+
+	_, err := Sync(m, s, PlanSync(m, s, opts), opts)
+	if err == nil || !strings.Contains(err.Error(), "no sha256") {
+		t.Errorf("Expected unverified download to be refused, got %v", err)
+	}
+
+	// Locking would otherwise pin whatever was downloaded
+	tool, _ := m.Tool("t")
+	if _, err := LockTool(m, tool, opts.CacheDir, true); err == nil || !strings.Contains(err.Error(), "no sha256") {
+		t.Errorf("Expected unverified download not to be locked, got %v", err)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	writeTarGz(t, archivePath, map[string]string{"bin/tool": "x"})
+	content, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := FileSHA256(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	cacheDir := filepath.Join(dir, "cache")
+	url := server.URL + "/releases/tool.tar.gz"
+
+	path, err := Fetch(url, digest, cacheDir)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if filepath.Base(path) != "tool.tar.gz" {
+		t.Errorf("Expected file name to be kept, got %s", path)
+	}
+
+	// A verified download is reused
+	if _, err := Fetch(url, digest, cacheDir); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected cached download to be reused, got %d requests", requests)
+	}
+
+	if _, err := Fetch(url, strings.Repeat("0", 64), cacheDir); err == nil {
+		t.Error("Expected checksum mismatch")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected mismatched download to be removed")
+	}
+}
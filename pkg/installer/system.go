@@ -0,0 +1,134 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/repoleved08/bii/pkg/archive"
+)
+
+// SystemDestDir is where --system installs binaries
+const SystemDestDir = "/usr/local/bin"
+
+// escalationTools are tried in order to run commands as root
+var escalationTools = []string{"sudo", "doas"}
+
+// Escalator runs commands as root, through sudo or doas unless bii already
+// runs as root
+type Escalator struct {
+	// Prefix is the command that escalates, e.g. ["sudo", "--"]. It is
+	// empty when running as root.
+	Prefix []string
+}
+
+// FindEscalator returns an Escalator for the current user
+func FindEscalator() (Escalator, error) {
+	if runtime.GOOS == "windows" {
+		return Escalator{}, fmt.Errorf("system installs are not supported on windows")
+	}
+	
+	if os.Geteuid() == 0 {
+		return Escalator{}, nil
+	}
+	
+	for _, tool := range escalationTools {
+		if path, err := exec.LookPath(tool); err == nil {
+			return Escalator{Prefix: []string{path, "--"}}, nil
+		}
+	}
+	
+	return Escalator{}, fmt.Errorf("%w: run as root or install sudo or doas", ErrNeedsRoot)
+}
+
+// Command returns the command that runs name with args as root. Its
+// standard streams are connected to bii's so sudo can ask for a password.
+func (e Escalator) Command(name string, args ...string) *exec.Cmd {
+	argv := append(append(append([]string{}, e.Prefix...), name), args...)
+	
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// Run runs name with args as root
+func (e Escalator) Run(name string, args ...string) error {
+	if err := e.Command(name, args...).Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// WriteFile writes content to path as root, owned by root with the given
+// mode. The previous content is kept next to it like for user files.
+func (e Escalator) WriteFile(path string, content []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp("", "bii-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	
+	if err := e.Run("install", "-d", "-m", "0755", filepath.Dir(path)); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if err := e.Run("cp", "-p", path, path+".bii.bak"); err != nil {
+			return err
+		}
+	}
+	return e.Run("install", "-o", "0", "-g", "0", "-m", fmt.Sprintf("%04o", mode.Perm()), tmp.Name(), path)
+}
+
+// InstallSystem installs binaries from an archive to a system directory.
+// The archive is extracted to a staging directory as the current user, and
+// only the final move runs as root, which leaves the files owned by root
+// with mode 0755.
+func InstallSystem(archivePath, destDir string, binaries []string, esc Escalator) ([]string, error) {
+	staged, err := archive.Stage(archivePath, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("extraction failed: %w", err)
+	}
+	defer staged.Cleanup()
+	
+	return InstallSystemStaged(staged, destDir, binaries, esc)
+}
+
+// InstallSystemStaged is InstallSystem for an archive that was already
+// staged
+func InstallSystemStaged(staged *archive.Staged, destDir string, binaries []string, esc Escalator) ([]string, error) {
+	if len(binaries) == 0 {
+		return nil, archive.ErrNoBinaries
+	}
+	
+	if err := esc.Run("install", "-d", "-m", "0755", destDir); err != nil {
+		return nil, err
+	}
+	
+	var installed []string
+	for _, b := range binaries {
+		src, ok := staged.Path(b)
+		if !ok {
+			return installed, fmt.Errorf("%s was not staged", b)
+		}
+		
+		dest := filepath.Join(destDir, filepath.Base(b))
+		if err := esc.Run("install", "-o", "0", "-g", "0", "-m", "0755", src, dest); err != nil {
+			return installed, err
+		}
+		installed = append(installed, dest)
+	}
+	
+	return installed, nil
+}
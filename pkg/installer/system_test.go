@@ -0,0 +1,96 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEscalatorCommand(t *testing.T) {
+	esc := Escalator{Prefix: []string{"/usr/bin/sudo", "--"}}
+
+	cmd := esc.Command("install", "-d", "/usr/local/bin")
+	expected := []string{"/usr/bin/sudo", "--", "install", "-d", "/usr/local/bin"}
+	if !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("Expected %v, got %v", expected, cmd.Args)
+	}
+
+	// Building a command doesn't change the prefix
+	esc.Command("true")
+	if len(esc.Prefix) != 2 {
+		t.Errorf("Expected prefix to stay the same, got %v", esc.Prefix)
+	}
+
+	if args := (Escalator{}).Command("true").Args; !reflect.DeepEqual(args, []string{"true"}) {
+		t.Errorf("Expected command to run directly as root, got %v", args)
+	}
+}
+
+func TestFindEscalator(t *testing.T) {
+	if os.Geteuid() == 0 {
+		esc, err := FindEscalator()
+		if err != nil || len(esc.Prefix) != 0 {
+			t.Errorf("Expected no escalation as root, got %v, %v", esc, err)
+		}
+		return
+	}
+
+	bin := t.TempDir()
+	doas := filepath.Join(bin, "doas")
+	if err := os.WriteFile(doas, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	esc, err := FindEscalator()
+	if err != nil {
+		t.Fatalf("FindEscalator failed: %v", err)
+	}
+	if esc.Prefix[0] != doas {
+		t.Errorf("Expected doas, got %v", esc.Prefix)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := FindEscalator(); err == nil {
+		t.Error("Expected error without sudo or doas")
+	}
+}
+
+func TestInstallSystem(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Installing with root ownership needs root")
+	}
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool.tar.gz")
+	writeTarGz(t, archivePath, map[string]string{"bin/tool": "x"})
+	dest := filepath.Join(dir, "usr", "local", "bin")
+
+	installed, err := InstallSystem(archivePath, dest, []string{"bin/tool"}, Escalator{})
+	if err != nil {
+		t.Fatalf("InstallSystem failed: %v", err)
+	}
+	if len(installed) != 1 || installed[0] != filepath.Join(dest, "tool") {
+		t.Fatalf("Unexpected installed files %v", installed)
+	}
+
+	info, err := os.Stat(installed[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755, got %04o", info.Mode().Perm())
+	}
+
+	config := filepath.Join(dir, "etc", "profile.d", "bii.sh")
+	if err := (Escalator{}).WriteFile(config, []byte("v1\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := (Escalator{}).WriteFile(config, []byte("v2\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if backup, _ := os.ReadFile(config + ".bii.bak"); string(backup) != "v1\n" {
+		t.Errorf("Expected previous content to be backed up, got %q", backup)
+	}
+}
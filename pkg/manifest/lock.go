@@ -0,0 +1,111 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName is the lockfile written next to the manifest
+const LockFileName = "bii.lock"
+
+// LockVersion is the version of the lockfile format
+const LockVersion = 1
+
+// Lock pins the exact archives and binaries of a manifest's tools
+type Lock struct {
+	Version int          `yaml:"version"`
+	Tools   []LockedTool `yaml:"tools"`
+}
+
+// LockedTool is a tool resolved to an exact archive
+type LockedTool struct {
+	Name    string `yaml:"name"`
+	Source  string `yaml:"source"`
+	Version string `yaml:"version,omitempty"`
+	// SHA256 is the digest of the archive
+	SHA256   string         `yaml:"sha256"`
+	Binaries []LockedBinary `yaml:"binaries"`
+}
+
+// LockedBinary is a file installed from a locked archive
+type LockedBinary struct {
+	// Entry is the name of the file in the archive
+	Entry string `yaml:"entry"`
+	// Name is the file name it is installed as
+	Name   string `yaml:"name"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// LockPath returns the lockfile that belongs to a manifest file
+func LockPath(manifestPath string) string {
+	return filepath.Join(filepath.Dir(manifestPath), LockFileName)
+}
+
+// LoadLock reads a lockfile
+func LoadLock(path string) (*Lock, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	
+	var l Lock
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(&l); err != nil {
+		return nil, fmt.Errorf("invalid lockfile %s: %w", path, err)
+	}
+	if l.Version > LockVersion {
+		return nil, fmt.Errorf("lockfile %s was written by a newer bii (version %d)", path, l.Version)
+	}
+	
+	return &l, nil
+}
+
+// Save writes the lockfile with tools and binaries sorted, so that it
+// diffs cleanly
+func (l *Lock) Save(path string) error {
+	l.Version = LockVersion
+	
+	sort.Slice(l.Tools, func(i, j int) bool { return l.Tools[i].Name < l.Tools[j].Name })
+	for _, t := range l.Tools {
+		sort.Slice(t.Binaries, func(i, j int) bool { return t.Binaries[i].Entry < t.Binaries[j].Entry })
+	}
+	
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by bii lock. Do not edit.\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(l); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Tool returns the locked tool with the given name
+func (l *Lock) Tool(name string) (LockedTool, bool) {
+	for _, t := range l.Tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return LockedTool{}, false
+}
+
+// Binary returns the locked binary for an archive entry
+func (t LockedTool) Binary(entry string) (LockedBinary, bool) {
+	for _, b := range t.Binaries {
+		if b.Entry == entry {
+			return b, true
+		}
+	}
+	return LockedBinary{}, false
+}
@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+
+	lock := &Lock{Tools: []LockedTool{
+		{Name: "zeta", Source: "z.zip", SHA256: "z"},
+		{Name: "alpha", Source: "a.zip", SHA256: "a", Binaries: []LockedBinary{
+			{Entry: "bin/b", Name: "b", SHA256: "2"},
+			{Entry: "bin/a", Name: "a", SHA256: "1"},
+		}},
+	}}
+	if err := lock.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "# Generated by bii lock") {
+		t.Errorf("Expected header comment, got %q", content)
+	}
+
+	loaded, err := LoadLock(path)
+	if err != nil {
+		t.Fatalf("LoadLock failed: %v", err)
+	}
+	if loaded.Version != LockVersion || loaded.Tools[0].Name != "alpha" {
+		t.Errorf("Expected sorted tools, got %+v", loaded.Tools)
+	}
+
+	alpha, ok := loaded.Tool("alpha")
+	if !ok {
+		t.Fatal("Expected alpha to be locked")
+	}
+	if alpha.Binaries[0].Entry != "bin/a" {
+		t.Errorf("Expected sorted binaries, got %+v", alpha.Binaries)
+	}
+	if b, ok := alpha.Binary("bin/b"); !ok || b.SHA256 != "2" {
+		t.Errorf("Unexpected binary lookup result %+v", b)
+	}
+
+	if LockPath(filepath.Join("project", FileName)) != filepath.Join("project", LockFileName) {
+		t.Error("Expected lockfile next to the manifest")
+	}
+}
+
+func TestLoadLockNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+	if err := os.WriteFile(path, []byte("version: 99\ntools: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadLock(path); err == nil {
+		t.Error("Expected error for newer lockfile version")
+	}
+}
@@ -0,0 +1,198 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the manifest file bii looks for in the current directory
+const FileName = "bii.yaml"
+
+// Manifest lists the tools a machine should have installed
+type Manifest struct {
+	// Dest is the default install directory for all tools
+	Dest  string `yaml:"dest,omitempty"`
+	Tools []Tool `yaml:"tools"`
+	
+	// dir is the directory the manifest was loaded from
+	dir string
+}
+
+// Tool is a tool installed from an archive
+type Tool struct {
+	Name string `yaml:"name"`
+	// Source is an archive path, relative to the manifest, or an http(s) URL
+	Source  string `yaml:"source"`
+	Version string `yaml:"version,omitempty"`
+	// SHA256 is the expected digest of the archive
+	SHA256 string `yaml:"sha256,omitempty"`
+	// Binaries selects entries by name or base name; all detected binaries
+	// are installed when empty
+	Binaries []string `yaml:"binaries,omitempty"`
+	// Rename maps a binary's base name to the name it is installed as
+	Rename map[string]string `yaml:"rename,omitempty"`
+	Dest   string            `yaml:"dest,omitempty"`
+}
+
+// Load reads and validates a manifest file
+func Load(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	
+	m, err := Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	m.dir = filepath.Dir(abs)
+	
+	return m, nil
+}
+
+// Parse parses and validates manifest content. Relative sources are
+// resolved against the current directory.
+func Parse(content []byte) (*Manifest, error) {
+	var m Manifest
+	
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	
+	// Digests are compared the way bii computes them, in lower case
+	for i := range m.Tools {
+		m.Tools[i].SHA256 = strings.ToLower(m.Tools[i].SHA256)
+	}
+	
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	
+	return &m, nil
+}
+
+// Validate checks that every tool is complete, names are unique and no
+// two tools install the same file, as far as their binaries are listed
+func (m *Manifest) Validate() error {
+	seen := make(map[string]bool)
+	// owners maps the files of tools that list their binaries to the tool
+	owners := make(map[string]string)
+	
+	for i, t := range m.Tools {
+		if t.Name == "" {
+			return fmt.Errorf("tool %d: name is required", i+1)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("tool %s: listed more than once", t.Name)
+		}
+		seen[t.Name] = true
+		
+		if t.Source == "" {
+			return fmt.Errorf("tool %s: source is required", t.Name)
+		}
+		
+		if t.SHA256 != "" {
+			if b, err := hex.DecodeString(t.SHA256); err != nil || len(b) != 32 {
+				return fmt.Errorf("tool %s: sha256 must be 64 hex characters", t.Name)
+			}
+		}
+		
+		for from, to := range t.Rename {
+			if to == "" || to != filepath.Base(to) || to == "." || to == ".." {
+				return fmt.Errorf("tool %s: invalid name to rename %s to: %q", t.Name, from, to)
+			}
+		}
+		
+		// An empty directory is the default, which is the same for every tool
+		dir := t.Dest
+		if dir == "" {
+			dir = m.Dest
+		}
+		for _, b := range t.Binaries {
+			name := filepath.Base(b)
+			if renamed, ok := t.Rename[name]; ok {
+				name = renamed
+			}
+			file := filepath.Join(dir, name)
+			if owner, ok := owners[file]; ok && owner != t.Name {
+				return fmt.Errorf("tool %s: %s is also installed by %s", t.Name, name, owner)
+			}
+			owners[file] = t.Name
+		}
+	}
+	
+	return nil
+}
+
+// Tool returns the tool with the given name
+func (m *Manifest) Tool(name string) (Tool, bool) {
+	for _, t := range m.Tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+// Source returns where to get a tool's archive: a URL, or an absolute path
+// with relative paths resolved against the manifest's directory
+func (m *Manifest) Source(t Tool) string {
+	if IsURL(t.Source) {
+		return t.Source
+	}
+	
+	path := expandHome(t.Source)
+	if !filepath.IsAbs(path) && m.dir != "" {
+		path = filepath.Join(m.dir, path)
+	}
+	return path
+}
+
+// DestDir returns the directory a tool is installed to
+func (m *Manifest) DestDir(t Tool, defaultDir string) string {
+	dir := t.Dest
+	if dir == "" {
+		dir = m.Dest
+	}
+	if dir == "" {
+		return defaultDir
+	}
+	
+	dir = expandHome(dir)
+	if !filepath.IsAbs(dir) && m.dir != "" {
+		dir = filepath.Join(m.dir, dir)
+	}
+	return dir
+}
+
+// IsURL reports whether a source is downloaded rather than read from disk
+func IsURL(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + path[1:]
+}
@@ -0,0 +1,117 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+
+	content := `dest: ~/tools/bin
+tools:
+  - name: terraform
+    source: https://example.com/terraform_1.6.0_linux_amd64.zip
+    version: 1.6.0
+    sha256: 0123456789ABCDEF0123456789abcdef0123456789ABCDEF0123456789abcdef
+  - name: local
+    source: archives/local.tar.gz
+    binaries: [tool]
+    rename:
+      tool: local-tool
+    dest: /opt/bin
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(m.Tools) != 2 {
+		t.Fatalf("Expected 2 tools, got %d", len(m.Tools))
+	}
+
+	terraform, ok := m.Tool("terraform")
+	if !ok {
+		t.Fatal("Expected terraform to be listed")
+	}
+	if terraform.SHA256 != strings.Repeat("0123456789abcdef", 4) {
+		t.Errorf("Expected sha256 in lower case, got %s", terraform.SHA256)
+	}
+	if m.Source(terraform) != terraform.Source {
+		t.Errorf("Expected URL source to be kept, got %s", m.Source(terraform))
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("Cannot get home directory")
+	}
+	if dest := m.DestDir(terraform, "/default"); dest != filepath.Join(home, "tools", "bin") {
+		t.Errorf("Expected manifest dest, got %s", dest)
+	}
+
+	local, _ := m.Tool("local")
+	if src := m.Source(local); src != filepath.Join(dir, "archives", "local.tar.gz") {
+		t.Errorf("Expected source relative to manifest, got %s", src)
+	}
+	if dest := m.DestDir(local, "/default"); dest != "/opt/bin" {
+		t.Errorf("Expected tool dest, got %s", dest)
+	}
+	if local.Rename["tool"] != "local-tool" {
+		t.Errorf("Expected rename to be parsed, got %v", local.Rename)
+	}
+
+	m.Dest = ""
+	if dest := m.DestDir(terraform, "/default"); dest != "/default" {
+		t.Errorf("Expected default dest, got %s", dest)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errText string
+	}{
+		{"Missing name", "tools:\n  - source: a.zip\n", "name is required"},
+		{"Missing source", "tools:\n  - name: a\n", "source is required"},
+		{"Duplicate", "tools:\n  - {name: a, source: a.zip}\n  - {name: a, source: b.zip}\n", "more than once"},
+		{"Bad checksum", "tools:\n  - {name: a, source: a.zip, sha256: abc}\n", "sha256"},
+		{"Bad rename", "tools:\n  - {name: a, source: a.zip, rename: {a: ../a}}\n", "invalid name"},
+		{"Unknown field", "tools:\n  - {name: a, source: a.zip, checksum: x}\n", "checksum"},
+		{"Same file", "tools:\n  - {name: a, source: a.zip, binaries: [bin/x]}\n  - {name: b, source: b.zip, binaries: [x]}\n", "also installed by a"},
+		{"Same file after rename", "tools:\n  - {name: a, source: a.zip, binaries: [x]}\n  - {name: b, source: b.zip, binaries: [y], rename: {y: x}}\n", "also installed by a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/a.zip": true,
+		"http://example.com/a.zip":  true,
+		"ftp://example.com/a.zip":   false,
+		"a.zip":                     false,
+		"/tmp/a.zip":                false,
+		"https:///a.zip":            false,
+	}
+
+	for source, expected := range tests {
+		if result := IsURL(source); result != expected {
+			t.Errorf("IsURL(%q) = %v; want %v", source, result, expected)
+		}
+	}
+}
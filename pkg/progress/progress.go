@@ -0,0 +1,87 @@
+// Package progress describes what an installation is doing while it runs,
+// for callers that want to show progress
+package progress
+
+import "io"
+
+// Kind is the kind of an Event
+type Kind string
+
+const (
+	// EntryStarted is sent before an archive entry is written
+	EntryStarted Kind = "entry_started"
+	// BytesWritten is sent as an entry or a download is written
+	BytesWritten Kind = "bytes_written"
+	// EntryDone is sent once an entry or a download is written
+	EntryDone Kind = "entry_done"
+	// Downloading is sent before an archive is downloaded
+	Downloading Kind = "downloading"
+	// Verified is sent once an archive and its binaries match the lock
+	Verified Kind = "verified"
+	// Installed is sent for every binary moved into place
+	Installed Kind = "installed"
+	// PathUpdated is sent once PATH configuration files were changed
+	PathUpdated Kind = "path_updated"
+)
+
+// Event is something that happened during an installation
+type Event struct {
+	Kind Kind
+	// Archive is the archive being installed, if known
+	Archive string
+	// Entry is the archive entry, or the URL being downloaded
+	Entry string
+	// Bytes is how much has been written so far
+	Bytes int64
+	// Total is the size being written, or -1 if unknown
+	Total int64
+	// Path is the file an Installed event moved into place
+	Path string
+	// Files are the files a PathUpdated event changed
+	Files []string
+}
+
+// Func receives events. Events may be sent from several goroutines at once.
+type Func func(Event)
+
+// Emit sends e to f, which may be nil
+func (f Func) Emit(e Event) {
+	if f != nil {
+		f(e)
+	}
+}
+
+// ForArchive returns a Func that sets the archive of every event before
+// passing it to f
+func (f Func) ForArchive(archive string) Func {
+	if f == nil {
+		return nil
+	}
+	return func(e Event) {
+		e.Archive = archive
+		f(e)
+	}
+}
+
+// Writer returns a writer that writes to w and sends a BytesWritten event
+// for e after every write
+func (f Func) Writer(w io.Writer, e Event) io.Writer {
+	if f == nil {
+		return w
+	}
+	e.Kind = BytesWritten
+	return &writer{w: w, fn: f, event: e}
+}
+
+type writer struct {
+	w     io.Writer
+	fn    Func
+	event Event
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.event.Bytes += int64(n)
+	w.fn(w.event)
+	return n, err
+}
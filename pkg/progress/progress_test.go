@@ -0,0 +1,40 @@
+package progress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var events []Event
+	fn := Func(func(e Event) { events = append(events, e) }).ForArchive("tool.tar.gz")
+
+	var buf bytes.Buffer
+	w := fn.Writer(&buf, Event{Entry: "bin/tool", Total: 6})
+	w.Write([]byte("abc"))
+	w.Write([]byte("def"))
+
+	if buf.String() != "abcdef" {
+		t.Errorf("Unexpected content %q", buf.String())
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %v", events)
+	}
+	last := events[1]
+	if last.Kind != BytesWritten || last.Bytes != 6 || last.Total != 6 || last.Archive != "tool.tar.gz" {
+		t.Errorf("Unexpected event %+v", last)
+	}
+}
+
+func TestNilFunc(t *testing.T) {
+	var fn Func
+	fn.Emit(Event{Kind: EntryStarted})
+
+	var buf bytes.Buffer
+	if w := fn.Writer(&buf, Event{}); w != &buf {
+		t.Error("Expected a nil Func not to wrap the writer")
+	}
+	if fn.ForArchive("tool.tar.gz") != nil {
+		t.Error("Expected ForArchive of a nil Func to be nil")
+	}
+}
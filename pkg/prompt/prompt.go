@@ -0,0 +1,141 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// maxAttempts is how often a question is repeated after an invalid answer
+const maxAttempts = 3
+
+var (
+	// ErrNonInteractive is returned when a question can't be asked because
+	// stdin is not a terminal
+	ErrNonInteractive = errors.New("stdin is not a terminal; pass --yes or set BII_ASSUME_YES=1 to continue without prompting")
+	// ErrNoAnswer is returned when input ends before a question is answered
+	ErrNoAnswer = errors.New("no answer given")
+	// ErrDeclined is wrapped by the errors of operations the user said no
+	// to, such as bii.ErrCancelled
+	ErrDeclined = errors.New("cancelled")
+)
+
+// Prompter asks the user questions
+type Prompter interface {
+	// Confirm asks a yes/no question. An empty answer selects defaultYes.
+	Confirm(question string, defaultYes bool) (bool, error)
+}
+
+// Stdio returns a Prompter that reads answers from stdin and writes
+// questions to out. If stdin is not a terminal, every question fails with
+// ErrNonInteractive instead of reading whatever happens to be piped in.
+func Stdio(out io.Writer) Prompter {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return NonInteractive{}
+	}
+	return NewLine(os.Stdin, out)
+}
+
+// ConfirmContext asks p a question like Confirm, but gives up when ctx is
+// done, such as when bii is interrupted while waiting for an answer
+func ConfirmContext(ctx context.Context, p Prompter, question string, defaultYes bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	
+	type answer struct {
+		ok  bool
+		err error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		ok, err := p.Confirm(question, defaultYes)
+		answers <- answer{ok, err}
+	}()
+	
+	select {
+	case a := <-answers:
+		return a.ok, a.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// AssumeYes answers yes to every question without asking
+type AssumeYes struct{}
+
+// Confirm implements Prompter
+func (AssumeYes) Confirm(question string, defaultYes bool) (bool, error) {
+	return true, nil
+}
+
+// NonInteractive fails every question with ErrNonInteractive
+type NonInteractive struct{}
+
+// Confirm implements Prompter
+func (NonInteractive) Confirm(question string, defaultYes bool) (bool, error) {
+	return false, ErrNonInteractive
+}
+
+// Line asks questions on out and reads one answer per line from in
+type Line struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewLine returns a Line prompter
+func NewLine(in io.Reader, out io.Writer) *Line {
+	return &Line{in: bufio.NewReader(in), out: out}
+}
+
+// Confirm implements Prompter
+func (l *Line) Confirm(question string, defaultYes bool) (bool, error) {
+	choices := "[y/N]"
+	if defaultYes {
+		choices = "[Y/n]"
+	}
+	
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		fmt.Fprintf(l.out, "%s %s: ", question, choices)
+		
+		line, err := l.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			// Don't leave the cursor after the question
+			fmt.Fprintln(l.out)
+			if err == io.EOF {
+				return false, ErrNoAnswer
+			}
+			return false, err
+		}
+		
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return defaultYes, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		
+		fmt.Fprintln(l.out, "Please answer y or n.")
+	}
+	
+	return false, ErrNoAnswer
+}
+
+// EnvAssumeYes reports whether the BII_ASSUME_YES environment variable asks
+// for questions to be answered with yes
+func EnvAssumeYes() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("BII_ASSUME_YES"))) {
+	case "1", "true", "yes", "y", "on":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,118 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineConfirm(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		defaultYes bool
+		expected   bool
+		err        error
+	}{
+		{"Yes", "y\n", false, true, nil},
+		{"Yes uppercase word", "YES\n", false, true, nil},
+		{"No", "n\n", true, false, nil},
+		{"Empty selects default yes", "\n", true, true, nil},
+		{"Empty selects default no", "\n", false, false, nil},
+		{"Answer without newline", "y", false, true, nil},
+		{"Invalid then valid", "maybe\nyes\n", false, true, nil},
+		{"Too many invalid answers", "a\nb\nc\nd\n", true, false, ErrNoAnswer},
+		{"EOF is not yes", "", true, false, ErrNoAnswer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := NewLine(strings.NewReader(tt.input), &out)
+
+			result, err := p.Confirm("Continue?", tt.defaultYes)
+			if err != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+			if !strings.Contains(out.String(), "Continue?") {
+				t.Errorf("Question not written, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestLineConfirmShowsDefault(t *testing.T) {
+	var out bytes.Buffer
+	p := NewLine(strings.NewReader("\n\n"), &out)
+
+	p.Confirm("A?", true)
+	p.Confirm("B?", false)
+
+	if !strings.Contains(out.String(), "A? [Y/n]") || !strings.Contains(out.String(), "B? [y/N]") {
+		t.Errorf("Unexpected prompts: %q", out.String())
+	}
+}
+
+func TestStdioNonTerminal(t *testing.T) {
+	// Tests run with stdin redirected, so Stdio must refuse to prompt
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w.WriteString("y\n")
+	w.Close()
+
+	original := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = original }()
+
+	_, err = Stdio(&bytes.Buffer{}).Confirm("Continue?", true)
+	if err != ErrNonInteractive {
+		t.Errorf("Expected ErrNonInteractive, got %v", err)
+	}
+}
+
+func TestConfirmContext(t *testing.T) {
+	ok, err := ConfirmContext(context.Background(), NewLine(strings.NewReader("y\n"), io.Discard), "Continue?", false)
+	if !ok || err != nil {
+		t.Errorf("Expected yes, got %v, %v", ok, err)
+	}
+
+	// A question nobody answers is given up on once cancelled
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := ConfirmContext(ctx, NewLine(r, io.Discard), "Continue?", true); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestEnvAssumeYes(t *testing.T) {
+	tests := map[string]bool{
+		"":      false,
+		"0":     false,
+		"false": false,
+		"1":     true,
+		"true":  true,
+		"YES":   true,
+	}
+
+	for value, expected := range tests {
+		t.Setenv("BII_ASSUME_YES", value)
+		if result := EnvAssumeYes(); result != expected {
+			t.Errorf("BII_ASSUME_YES=%q: expected %v, got %v", value, expected, result)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	blockBegin = "# >>> bii >>>"
+	blockEnd   = "# <<< bii <<<"
+
+	// legacyMarker preceded each line appended by older bii versions
+	legacyMarker = "# Added by bii"
+
+	// backupSuffix is appended to a config file's name for its backup copy
+	backupSuffix = ".bii.bak"
+)
+
+// splitBlock separates config file content into the text before the managed
+// block, the lines inside it and the text after it. Lines appended by older
+// bii versions outside the block are moved into it.
+func splitBlock(content string) (before string, lines []string, after string) {
+	all := strings.SplitAfter(content, "\n")
+	
+	var head, tail []string
+	inBlock, seenBlock := false, false
+	
+	for i := 0; i < len(all); i++ {
+		line := strings.TrimRight(all[i], "\n")
+		
+		switch {
+		case !inBlock && line == blockBegin:
+			inBlock, seenBlock = true, true
+		case inBlock && line == blockEnd:
+			inBlock = false
+		case inBlock:
+			lines = appendUnique(lines, line)
+		case line == legacyMarker && i+1 < len(all):
+			i++
+			lines = appendUnique(lines, strings.TrimRight(all[i], "\n"))
+			// Drop the blank line older versions wrote before the marker
+			if n := len(head); !seenBlock && n > 0 && head[n-1] == "\n" {
+				head = head[:n-1]
+			} else if n := len(tail); seenBlock && n > 0 && tail[n-1] == "\n" {
+				tail = tail[:n-1]
+			}
+		case seenBlock:
+			tail = append(tail, all[i])
+		default:
+			head = append(head, all[i])
+		}
+	}
+	
+	return strings.Join(head, ""), lines, strings.Join(tail, "")
+}
+
+// joinBlock is the inverse of splitBlock. An empty block is omitted entirely.
+func joinBlock(before string, lines []string, after string) string {
+	if len(lines) == 0 {
+		return strings.TrimRight(before, "\n") + trimLeadingBlank(before, after)
+	}
+	
+	var b strings.Builder
+	b.WriteString(before)
+	if before != "" {
+		if !strings.HasSuffix(before, "\n") {
+			b.WriteString("\n")
+		}
+		if !strings.HasSuffix(before, "\n\n") {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString(blockBegin + "\n")
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(blockEnd + "\n")
+	b.WriteString(after)
+	
+	return b.String()
+}
+
+// trimLeadingBlank joins the remainder of a file after its block was removed
+func trimLeadingBlank(before, after string) string {
+	if before == "" {
+		return strings.TrimLeft(after, "\n")
+	}
+	if after == "" {
+		return "\n"
+	}
+	return "\n" + after
+}
+
+// Change is an edit to a shell config file
+type Change struct {
+	Path   string
+	Before string
+	After  string
+}
+
+// Diff returns the change as a unified diff
+func (c Change) Diff() string {
+	return unifiedDiff(c.Path, c.Before, c.After)
+}
+
+// apply writes the change, saving the previous content next to the file
+// first. bii's own files are removed once they end up empty; the user's
+// config files are kept, even if the bii block was all they held.
+func (c Change) apply() error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(c.Path); err == nil {
+		mode = info.Mode().Perm()
+		if err := os.WriteFile(c.Path+backupSuffix, []byte(c.Before), mode); err != nil {
+			return err
+		}
+	}
+	
+	if c.After == "" && ownFile(c.Path) {
+		return os.Remove(c.Path)
+	}
+	
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+		return err
+	}
+	
+	return os.WriteFile(c.Path, []byte(c.After), mode)
+}
+
+// ownFile reports whether path is a drop-in file bii creates for itself,
+// rather than a config file the user may have written to as well
+func ownFile(path string) bool {
+	switch filepath.Join(filepath.Base(filepath.Dir(path)), filepath.Base(path)) {
+	case filepath.Join("conf.d", "bii.fish"),
+		filepath.Join("environment.d", "50-bii.conf"),
+		filepath.Join("profile.d", "bii.sh"),
+		filepath.Join("paths.d", "bii"):
+		return true
+	}
+	return false
+}
+
+// planBlock returns the change that replacing the managed block of
+// configFile with the lines returned by fn would make, or nil if the file
+// would stay the same.
+func planBlock(configFile string, fn func(lines []string) []string) (*Change, error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	
+	before, lines, after := splitBlock(string(content))
+	updated := joinBlock(before, fn(lines), after)
+	
+	if updated == string(content) {
+		return nil, nil
+	}
+	
+	return &Change{Path: configFile, Before: string(content), After: updated}, nil
+}
+
+// updateBlock rewrites the managed block of configFile with the lines
+// returned by fn and reports whether the file changed
+func updateBlock(configFile string, fn func(lines []string) []string) (bool, error) {
+	change, err := planBlock(configFile, fn)
+	if err != nil || change == nil {
+		return false, err
+	}
+	
+	if err := change.apply(); err != nil {
+		return false, err
+	}
+	
+	return true, nil
+}
+
+// blockLines returns the lines of the managed block in configFile
+func blockLines(configFile string) ([]string, error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	
+	_, lines, _ := splitBlock(string(content))
+	return lines, nil
+}
+
+func appendUnique(lines []string, line string) []string {
+	for _, l := range lines {
+		if l == line {
+			return lines
+		}
+	}
+	return append(lines, line)
+}
+
+func removeLine(lines []string, line string) []string {
+	var kept []string
+	for _, l := range lines {
+		if l != line {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
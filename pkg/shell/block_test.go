@@ -0,0 +1,105 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlockRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		lines   []string
+	}{
+		{"Empty file", "", nil},
+		{"No block", "alias ll='ls -l'\n", nil},
+		{"Block only", blockBegin + "\nexport PATH=\"/a:$PATH\"\n" + blockEnd + "\n", []string{"export PATH=\"/a:$PATH\""}},
+		{"Block between content", "a\n\n" + blockBegin + "\nx\ny\n" + blockEnd + "\nb\n", []string{"x", "y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, lines, after := splitBlock(tt.content)
+			if strings.Join(lines, ",") != strings.Join(tt.lines, ",") {
+				t.Errorf("Expected lines %v, got %v", tt.lines, lines)
+			}
+			if got := joinBlock(before, lines, after); got != tt.content {
+				t.Errorf("Round trip changed content.\nExpected: %q\nGot: %q", tt.content, got)
+			}
+		})
+	}
+}
+
+func TestSplitBlockMigratesLegacyLines(t *testing.T) {
+	content := "a\n\n# Added by bii\nexport PATH=\"/old:$PATH\"\nb\n"
+
+	before, lines, after := splitBlock(content)
+	if len(lines) != 1 || lines[0] != "export PATH=\"/old:$PATH\"" {
+		t.Fatalf("Expected legacy line to be moved into block, got %v", lines)
+	}
+
+	got := joinBlock(before, lines, after)
+	want := "a\nb\n\n" + blockBegin + "\nexport PATH=\"/old:$PATH\"\n" + blockEnd + "\n"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestUpdateBlock(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".bashrc")
+	original := "# my config\nalias ll='ls -l'\n"
+	if err := os.WriteFile(configFile, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(line string) func([]string) []string {
+		return func(lines []string) []string { return appendUnique(lines, line) }
+	}
+
+	if _, err := updateBlock(configFile, add("one")); err != nil {
+		t.Fatalf("updateBlock failed: %v", err)
+	}
+	if _, err := updateBlock(configFile, add("two")); err != nil {
+		t.Fatalf("updateBlock failed: %v", err)
+	}
+
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := original + "\n" + blockBegin + "\none\ntwo\n" + blockEnd + "\n"
+	if string(content) != want {
+		t.Errorf("Expected %q, got %q", want, string(content))
+	}
+
+	info, err := os.Stat(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to be preserved, got %v", info.Mode().Perm())
+	}
+
+	// Backup holds the content from before the last edit
+	backup, err := os.ReadFile(configFile + backupSuffix)
+	if err != nil {
+		t.Fatalf("Backup not written: %v", err)
+	}
+	if !strings.Contains(string(backup), "one") || strings.Contains(string(backup), "two") {
+		t.Errorf("Unexpected backup content: %q", string(backup))
+	}
+
+	// Removing every line restores the original file
+	if _, err := updateBlock(configFile, func([]string) []string { return nil }); err != nil {
+		t.Fatalf("updateBlock failed: %v", err)
+	}
+	content, err = os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != original {
+		t.Errorf("Expected %q after removal, got %q", original, string(content))
+	}
+}
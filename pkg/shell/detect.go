@@ -0,0 +1,119 @@
+package shell
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// procRoot is where process information is read from
+	procRoot = "/proc"
+	// passwdFile is read for the user's login shell
+	passwdFile = "/etc/passwd"
+)
+
+// maxParentDepth bounds how far up the process tree parentShell looks, e.g.
+// past sudo, make or go run
+const maxParentDepth = 8
+
+// parentShell walks up the process tree from pid and returns the name of the
+// first shell it finds, or "" if there is none or /proc is unavailable.
+func parentShell(pid int) string {
+	for depth := 0; depth < maxParentDepth && pid > 1; depth++ {
+		dir := filepath.Join(procRoot, strconv.Itoa(pid))
+		
+		if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+			if shell := shellName(exe); shell != "" {
+				return shell
+			}
+		}
+		
+		// Interpreted shells such as xonsh run as "python3 /usr/bin/xonsh"
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+			args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+			for i := 0; i < len(args) && i < 2; i++ {
+				if shell := shellName(args[i]); shell != "" {
+					return shell
+				}
+			}
+		}
+		
+		ppid, ok := parentPid(dir)
+		if !ok {
+			return ""
+		}
+		pid = ppid
+	}
+	
+	return ""
+}
+
+// parentPid reads the parent pid from the stat file of a /proc entry
+func parentPid(dir string) (int, bool) {
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return 0, false
+	}
+	
+	// The command name in parentheses may itself contain spaces or parentheses
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return 0, false
+	}
+	
+	// Fields after the name are: state, ppid, ...
+	fields := strings.Fields(string(stat)[end+1:])
+	if len(fields) < 2 {
+		return 0, false
+	}
+	
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, false
+	}
+	
+	return ppid, true
+}
+
+// passwdShell returns the login shell of uid from the passwd file
+func passwdShell(uid int) string {
+	f, err := os.Open(passwdFile)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) != 7 || fields[2] != strconv.Itoa(uid) {
+			continue
+		}
+		return filepath.Base(fields[6])
+	}
+	
+	return ""
+}
+
+// shellName returns the shell a program path refers to, or "" if it isn't a
+// supported shell
+func shellName(path string) string {
+	// Login shells are started with a leading dash, e.g. "-bash"
+	name := strings.TrimPrefix(filepath.Base(path), "-")
+	
+	switch name {
+	case "ksh93":
+		name = "ksh"
+	case "powershell":
+		name = "pwsh"
+	}
+	
+	if _, err := shellSyntax(name); err != nil {
+		return ""
+	}
+	return name
+}
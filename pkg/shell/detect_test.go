@@ -0,0 +1,149 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeProcess describes an entry in a fake /proc tree
+type fakeProcess struct {
+	pid     int
+	ppid    int
+	exe     string
+	cmdline []string
+}
+
+// setFakeProc points procRoot at a temporary /proc tree with the given processes
+func setFakeProc(t *testing.T, procs ...fakeProcess) {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, p := range procs {
+		dir := filepath.Join(root, fmt.Sprint(p.pid))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if p.exe != "" {
+			if err := os.Symlink(p.exe, filepath.Join(dir, "exe")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cmdline := strings.Join(p.cmdline, "\x00") + "\x00"
+		if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0644); err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Base(p.exe)
+		stat := fmt.Sprintf("%d (%s) S %d %d 0 0", p.pid, name, p.ppid, p.ppid)
+		if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	original := procRoot
+	procRoot = root
+	t.Cleanup(func() { procRoot = original })
+}
+
+func TestParentShell(t *testing.T) {
+	tests := []struct {
+		name     string
+		procs    []fakeProcess
+		expected string
+	}{
+		{
+			"Direct parent",
+			[]fakeProcess{{pid: 100, ppid: 1, exe: "/usr/bin/zsh", cmdline: []string{"zsh"}}},
+			"zsh",
+		},
+		{
+			"Through sudo and make",
+			[]fakeProcess{
+				{pid: 100, ppid: 90, exe: "/usr/bin/make", cmdline: []string{"make", "install"}},
+				{pid: 90, ppid: 80, exe: "/usr/bin/sudo", cmdline: []string{"sudo", "make"}},
+				{pid: 80, ppid: 1, exe: "/usr/bin/fish", cmdline: []string{"fish"}},
+			},
+			"fish",
+		},
+		{
+			"Login shell",
+			[]fakeProcess{{pid: 100, ppid: 1, exe: "/bin/bash", cmdline: []string{"-bash"}}},
+			"bash",
+		},
+		{
+			"Interpreted shell",
+			[]fakeProcess{{pid: 100, ppid: 1, exe: "/usr/bin/python3.12", cmdline: []string{"/usr/bin/python3", "/usr/bin/xonsh"}}},
+			"xonsh",
+		},
+		{
+			"Unreadable exe",
+			[]fakeProcess{{pid: 100, ppid: 1, cmdline: []string{"/usr/bin/nu"}}},
+			"nu",
+		},
+		{
+			"No shell",
+			[]fakeProcess{
+				{pid: 100, ppid: 90, exe: "/usr/bin/containerd-shim", cmdline: []string{"containerd-shim"}},
+				{pid: 90, ppid: 1, exe: "/sbin/init", cmdline: []string{"init"}},
+			},
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFakeProc(t, tt.procs...)
+
+			if result := parentShell(100); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParentPidWithSpacesInName(t *testing.T) {
+	dir := t.TempDir()
+	stat := "42 (tmux: server (1)) S 7 42 42 0"
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ppid, ok := parentPid(dir)
+	if !ok || ppid != 7 {
+		t.Errorf("Expected ppid 7, got %d (ok=%v)", ppid, ok)
+	}
+}
+
+func TestDetectShellFallbacks(t *testing.T) {
+	setFakeProc(t)
+
+	passwd := filepath.Join(t.TempDir(), "passwd")
+	content := fmt.Sprintf("root:x:0:0:root:/root:/bin/sh\nme:x:%d:%d::/home/me:/usr/bin/elvish\n", os.Getuid(), os.Getgid())
+	if os.Getuid() == 0 {
+		content = "root:x:0:0:root:/root:/usr/bin/elvish\n"
+	}
+	if err := os.WriteFile(passwd, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	original := passwdFile
+	passwdFile = passwd
+	defer func() { passwdFile = original }()
+
+	t.Setenv("SHELL", "/bin/zsh")
+	if result, err := DetectShell(); err != nil || result != "zsh" {
+		t.Errorf("Expected zsh from SHELL, got %q (%v)", result, err)
+	}
+
+	t.Setenv("SHELL", "")
+	if result, err := DetectShell(); err != nil || result != "elvish" {
+		t.Errorf("Expected elvish from passwd, got %q (%v)", result, err)
+	}
+
+	passwdFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := DetectShell(); err == nil {
+		t.Error("Expected error when no shell can be detected")
+	}
+}
@@ -0,0 +1,127 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// unifiedDiff returns a unified diff between two versions of a file. Config
+// files are small, so a plain longest-common-subsequence table is enough.
+func unifiedDiff(path, before, after string) string {
+	a, b := diffLines(before), diffLines(after)
+	
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	
+	// ops holds each line prefixed with ' ', '-' or '+'
+	var ops []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, "-"+a[i])
+			i++
+		default:
+			ops = append(ops, "+"+b[j])
+			j++
+		}
+	}
+	
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", path, path)
+	
+	for start := 0; start < len(ops); {
+		if ops[start][0] == ' ' {
+			start++
+			continue
+		}
+		
+		// Grow the hunk until the gap to the next change exceeds twice the context
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k][0] != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContext {
+				break
+			}
+		}
+		
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		to := end + diffContext
+		if to > len(ops) {
+			to = len(ops)
+		}
+		
+		writeHunk(&out, ops, from, to)
+		start = to
+	}
+	
+	return out.String()
+}
+
+// writeHunk writes ops[from:to] with its @@ header
+func writeHunk(out *strings.Builder, ops []string, from, to int) {
+	aStart, bStart := 1, 1
+	for _, op := range ops[:from] {
+		if op[0] != '+' {
+			aStart++
+		}
+		if op[0] != '-' {
+			bStart++
+		}
+	}
+	
+	aLen, bLen := 0, 0
+	for _, op := range ops[from:to] {
+		if op[0] != '+' {
+			aLen++
+		}
+		if op[0] != '-' {
+			bLen++
+		}
+	}
+	
+	// An empty range starts at the line before it
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+	
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, op := range ops[from:to] {
+		out.WriteString(op + "\n")
+	}
+}
+
+func diffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,39 @@
+package shell
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		expected string
+	}{
+		{
+			"New file",
+			"",
+			"one\ntwo\n",
+			"--- f\n+++ f\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			"Appended block",
+			"a\nb\nc\nd\ne\n",
+			"a\nb\nc\nd\ne\n\nx\n",
+			"--- f\n+++ f\n@@ -3,3 +3,5 @@\n c\n d\n e\n+\n+x\n",
+		},
+		{
+			"Separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"1\nX\n3\n4\n5\n6\n7\n8\n9\n10\n11\nY\n",
+			"--- f\n+++ f\n@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n 5\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+Y\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("f", tt.before, tt.after); got != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
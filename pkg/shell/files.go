@@ -0,0 +1,256 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// goos is the operating system config files are chosen for
+var goos = runtime.GOOS
+
+// configFile is a file bii writes PATH configuration to
+type configFile struct {
+	path string
+	// syntax is the language the file is written in: a shell name, "sh"
+	// for POSIX profiles or "environment.d" for systemd user environments
+	syntax string
+}
+
+// pathConfigFiles returns the files AddToPath writes for the given shell, so
+// that login shells, non-interactive shells and graphical sessions all see
+// the change and not just interactive terminals.
+func pathConfigFiles(shell string) ([]configFile, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	
+	var files []configFile
+	
+	switch shell {
+	case "bash":
+		rc, err := GetShellConfigPath(shell)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files,
+			configFile{rc, "bash"},
+			configFile{bashLoginFile(home), "sh"},
+		)
+	case "zsh":
+		// .zshenv is read by every zsh, including non-interactive ones
+		files = append(files, configFile{filepath.Join(zshDir(home), ".zshenv"), "zsh"})
+	case "fish":
+		files = append(files, configFile{filepath.Join(configHome(home), "fish", "conf.d", "bii.fish"), "fish"})
+	case "nu":
+		files = append(files, configFile{filepath.Join(nuConfigDir(home), "env.nu"), "nu"})
+	case "elvish", "xonsh", "tcsh", "csh", "pwsh":
+		rc, err := GetShellConfigPath(shell)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, configFile{rc, shell})
+	case "ksh", "mksh":
+		// The rc file is only read by interactive shells, .profile by login shells
+		rc, err := GetShellConfigPath(shell)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files,
+			configFile{rc, "sh"},
+			configFile{filepath.Join(home, ".profile"), "sh"},
+		)
+	case "sh", "dash":
+		files = append(files, configFile{filepath.Join(home, ".profile"), "sh"})
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedShell, shell)
+	}
+	
+	// Graphical sessions started by systemd read environment.d, not shell files
+	if goos == "linux" {
+		files = append(files, configFile{filepath.Join(configHome(home), "environment.d", "50-bii.conf"), "environment.d"})
+	}
+	
+	return files, nil
+}
+
+// managedFiles returns every file bii may have written for the given shell,
+// including the interactive config file used by older versions.
+func managedFiles(shell string) ([]string, error) {
+	files, err := pathConfigFiles(shell)
+	if err != nil {
+		return nil, err
+	}
+	
+	rc, err := GetShellConfigPath(shell)
+	if err != nil {
+		return nil, err
+	}
+	
+	paths := []string{rc}
+	for _, f := range files {
+		paths = appendUnique(paths, f.path)
+	}
+	
+	return paths, nil
+}
+
+// bashLoginFile returns the file a bash login shell reads. bash only reads
+// the first of these that exists, so writing to another one has no effect.
+func bashLoginFile(home string) string {
+	for _, name := range []string{".bash_profile", ".bash_login", ".profile"} {
+		path := filepath.Join(home, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	
+	// Terminal on macOS starts login shells, which look for .bash_profile first
+	if goos == "darwin" {
+		return filepath.Join(home, ".bash_profile")
+	}
+	return filepath.Join(home, ".profile")
+}
+
+// zshDir returns the directory zsh reads its startup files from
+func zshDir(home string) string {
+	if dir := os.Getenv("ZDOTDIR"); dir != "" {
+		return dir
+	}
+	return home
+}
+
+// nuConfigDir returns the directory nushell reads its config files from
+func nuConfigDir(home string) string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "nushell")
+	}
+	if goos == "darwin" {
+		return filepath.Join(home, "Library", "Application Support", "nushell")
+	}
+	return filepath.Join(home, ".config", "nushell")
+}
+
+// tcshConfigFile returns the file tcsh reads, which is .cshrc when there
+// is no .tcshrc
+func tcshConfigFile(home string) string {
+	tcshrc := filepath.Join(home, ".tcshrc")
+	if _, err := os.Stat(tcshrc); os.IsNotExist(err) {
+		cshrc := filepath.Join(home, ".cshrc")
+		if _, err := os.Stat(cshrc); err == nil {
+			return cshrc
+		}
+	}
+	return tcshrc
+}
+
+// configHome returns the XDG config directory
+func configHome(home string) string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	return filepath.Join(home, ".config")
+}
+
+// pathLine returns the line that prepends dir to PATH in the given syntax.
+// Lines skip directories already in PATH, since several of the files can be
+// read by the same session. dir is quoted for the syntax; directories that
+// can't be are rejected with ErrUnsupportedDir.
+func pathLine(syntax, dir string) (string, error) {
+	if err := checkDir(dir); err != nil {
+		return "", err
+	}
+	
+	switch syntax {
+	case "sh", "bash", "zsh":
+		q := shQuoteEscaper.Replace(dir)
+		return fmt.Sprintf("case \":$PATH:\" in *\":%s:\"*) ;; *) export PATH=\"%s:$PATH\" ;; esac", q, q), nil
+	case "fish":
+		q := fishWord(dir)
+		return fmt.Sprintf("contains %s $PATH; or set -gx PATH %s $PATH", q, q), nil
+	case "nu":
+		return fmt.Sprintf("$env.PATH = ($env.PATH | split row (char esep) | prepend %s | uniq)", nuString(dir)), nil
+	case "elvish":
+		q := strings.ReplaceAll(dir, "'", "''")
+		return fmt.Sprintf("if (not (has-value $paths '%s')) { set paths = ['%s' $@paths] }", q, q), nil
+	case "xonsh":
+		q := pyQuoteEscaper.Replace(dir)
+		return fmt.Sprintf("if '%s' not in $PATH: $PATH.insert(0, '%s')", q, q), nil
+	case "tcsh", "csh":
+		q, err := cshWord(syntax, dir)
+		if err != nil {
+			return "", err
+		}
+		if q == dir {
+			return fmt.Sprintf("if ( \":${PATH}:\" !~ *:%s:* ) setenv PATH \"%s:${PATH}\"", dir, dir), nil
+		}
+		return fmt.Sprintf("if ( \":${PATH}:\" !~ *:%s:* ) setenv PATH %s:\"${PATH}\"", q, q), nil
+	case "pwsh":
+		q := strings.ReplaceAll(dir, "'", "''")
+		return fmt.Sprintf("if (($env:PATH -split [IO.Path]::PathSeparator) -notcontains '%s') { $env:PATH = '%s' + [IO.Path]::PathSeparator + $env:PATH }", q, q), nil
+	case "environment.d":
+		if plainWord.MatchString(dir) {
+			return fmt.Sprintf("PATH=%s:${PATH}", dir), nil
+		}
+		// systemd expands variables even after unescaping them
+		if strings.Contains(dir, "$") {
+			return "", fmt.Errorf("%w for %s: %q", ErrUnsupportedDir, syntax, dir)
+		}
+		return fmt.Sprintf("PATH=\"%s:${PATH}\"", quoteEscaper.Replace(dir)), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedShell, syntax)
+	}
+}
+
+// checkDir rejects directories that can't be an entry of a colon-separated
+// list, or a line of a config file
+func checkDir(dir string) error {
+	if strings.ContainsAny(dir, ":\n\r\x00") {
+		return fmt.Errorf("%w: %q", ErrUnsupportedDir, dir)
+	}
+	return nil
+}
+
+// fishWord returns dir as a single fish word
+func fishWord(dir string) string {
+	if plainWord.MatchString(dir) {
+		return dir
+	}
+	return "'" + pyQuoteEscaper.Replace(dir) + "'"
+}
+
+// nuString returns dir as a nu string
+func nuString(dir string) string {
+	if strings.Contains(dir, "'") {
+		return "\"" + quoteEscaper.Replace(dir) + "\""
+	}
+	return "'" + dir + "'"
+}
+
+// cshWord returns dir as a single csh word. Single quotes keep csh from
+// expanding it, and from treating it as a pattern, but can't hold a single
+// quote.
+func cshWord(syntax, dir string) (string, error) {
+	if plainWord.MatchString(dir) {
+		return dir, nil
+	}
+	if strings.Contains(dir, "'") {
+		return "", fmt.Errorf("%w for %s: %q", ErrUnsupportedDir, syntax, dir)
+	}
+	return "'" + dir + "'", nil
+}
+
+var (
+	// plainWord matches directories that need no quoting in any syntax
+	plainWord = regexp.MustCompile(`^[\w/.,+@%=-]+$`)
+	// shQuoteEscaper escapes the characters sh expands inside double quotes
+	shQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+	// quoteEscaper escapes a double-quoted string in nu and environment.d
+	quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	// pyQuoteEscaper escapes a single-quoted string in fish and Python
+	pyQuoteEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+)
@@ -0,0 +1,105 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InitOptions describes the environment InitScript sets up
+type InitOptions struct {
+	// BinDirs are prepended to PATH
+	BinDirs []string
+	// ManDirs are prepended to MANPATH
+	ManDirs []string
+	// Completion is the command that prints the shell's completion script,
+	// such as "bii completion bash". Completion setup is skipped when empty.
+	Completion string
+}
+
+// InitScript returns shell code that sets up PATH, MANPATH and completion for
+// the given shell. It is meant to be evaluated on shell startup as an
+// alternative to AddToPath editing config files.
+func InitScript(shell string, opts InitOptions) (string, error) {
+	syntax, err := shellSyntax(shell)
+	if err != nil {
+		return "", err
+	}
+	
+	var lines []string
+	
+	for _, dir := range opts.BinDirs {
+		line, err := pathLine(syntax, dir)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	
+	for _, dir := range opts.ManDirs {
+		line, err := manPathLine(syntax, dir)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	
+	if opts.Completion != "" {
+		switch shell {
+		case "bash", "zsh":
+			lines = append(lines, fmt.Sprintf("source <(%s)", opts.Completion))
+		case "fish":
+			lines = append(lines, fmt.Sprintf("%s | source", opts.Completion))
+		}
+	}
+	
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// shellSyntax returns the syntax pathLine uses for the given shell
+func shellSyntax(shell string) (string, error) {
+	switch shell {
+	case "bash", "zsh", "fish", "nu", "elvish", "xonsh", "tcsh", "csh", "pwsh":
+		return shell, nil
+	case "ksh", "mksh", "sh", "dash":
+		return "sh", nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedShell, shell)
+	}
+}
+
+// manPathLine returns the line that prepends dir to MANPATH. An empty
+// MANPATH entry is kept so man still searches its default locations.
+func manPathLine(syntax, dir string) (string, error) {
+	if err := checkDir(dir); err != nil {
+		return "", err
+	}
+	
+	switch syntax {
+	case "fish":
+		q := fishWord(dir)
+		return fmt.Sprintf("contains %s $MANPATH; or set -gx MANPATH %s $MANPATH ''", q, q), nil
+	case "nu":
+		return fmt.Sprintf("$env.MANPATH = ($env.MANPATH? | default '' | split row (char esep) | prepend %s | uniq | str join (char esep))", nuString(dir)), nil
+	case "elvish":
+		q := strings.ReplaceAll(dir, "'", "''")
+		return fmt.Sprintf("use str; if (not (str:contains ':'$E:MANPATH':' ':%s:')) { set E:MANPATH = '%s:'$E:MANPATH }", q, q), nil
+	case "xonsh":
+		q := pyQuoteEscaper.Replace(dir)
+		return fmt.Sprintf("if '%s' not in ${...}.get('MANPATH', '').split(':'): $MANPATH = '%s:' + ${...}.get('MANPATH', '')", q, q), nil
+	case "tcsh", "csh":
+		q, err := cshWord(syntax, dir)
+		if err != nil {
+			return "", err
+		}
+		if q == dir {
+			return fmt.Sprintf("if ( ! $?MANPATH ) setenv MANPATH \"\"; if ( \":${MANPATH}:\" !~ *:%s:* ) setenv MANPATH \"%s:${MANPATH}\"", dir, dir), nil
+		}
+		return fmt.Sprintf("if ( ! $?MANPATH ) setenv MANPATH \"\"; if ( \":${MANPATH}:\" !~ *:%s:* ) setenv MANPATH %s:\"${MANPATH}\"", q, q), nil
+	case "pwsh":
+		q := strings.ReplaceAll(dir, "'", "''")
+		return fmt.Sprintf("if (($env:MANPATH -split [IO.Path]::PathSeparator) -notcontains '%s') { $env:MANPATH = '%s' + [IO.Path]::PathSeparator + $env:MANPATH }", q, q), nil
+	default:
+		q := shQuoteEscaper.Replace(dir)
+		return fmt.Sprintf("case \":$MANPATH:\" in *\":%s:\"*) ;; *) export MANPATH=\"%s:$MANPATH\" ;; esac", q, q), nil
+	}
+}
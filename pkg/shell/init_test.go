@@ -0,0 +1,119 @@
+package shell
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestInitScript(t *testing.T) {
+	opts := InitOptions{
+		BinDirs:    []string{"/test/bin"},
+		ManDirs:    []string{"/test/share/man"},
+		Completion: "bii completion",
+	}
+
+	tests := []struct {
+		shell    string
+		expected []string
+	}{
+		{"bash", []string{"export PATH=\"/test/bin:$PATH\"", "export MANPATH=\"/test/share/man:$MANPATH\"", "source <(bii completion)"}},
+		{"zsh", []string{"export PATH=\"/test/bin:$PATH\"", "source <(bii completion)"}},
+		{"fish", []string{"set -gx PATH /test/bin $PATH", "set -gx MANPATH /test/share/man $MANPATH ''", "bii completion | source"}},
+		{"ksh", []string{"export PATH=\"/test/bin:$PATH\"", "export MANPATH=\"/test/share/man:$MANPATH\""}},
+		{"nu", []string{"prepend '/test/bin'", "prepend '/test/share/man'"}},
+		{"tcsh", []string{"setenv PATH \"/test/bin:${PATH}\"", "setenv MANPATH \"/test/share/man:${MANPATH}\""}},
+		{"pwsh", []string{"$env:PATH = '/test/bin'", "$env:MANPATH = '/test/share/man'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			script, err := InitScript(tt.shell, opts)
+			if err != nil {
+				t.Fatalf("InitScript failed: %v", err)
+			}
+
+			for _, substr := range tt.expected {
+				if !strings.Contains(script, substr) {
+					t.Errorf("Script does not contain %q.\nGot: %s", substr, script)
+				}
+			}
+		})
+	}
+
+	// Completion is only offered where bii can generate it
+	script, err := InitScript("ksh", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(script, "bii completion") {
+		t.Errorf("Unexpected completion setup for ksh: %s", script)
+	}
+
+	if _, err := InitScript("unsupported", opts); err == nil {
+		t.Error("Expected error for unsupported shell")
+	}
+}
+
+func TestPathLineQuoting(t *testing.T) {
+	dirs := []string{"/opt/my tools/bin", "/opt/it's/bin", "/opt/a\"$b`c`\\d/*[x]"}
+	syntaxes := []string{"sh", "bash", "zsh", "fish", "nu", "elvish", "xonsh", "tcsh", "pwsh", "environment.d"}
+
+	for _, dir := range dirs {
+		for _, syntax := range syntaxes {
+			line, err := pathLine(syntax, dir)
+			if errors.Is(err, ErrUnsupportedDir) {
+				// csh can't quote a single quote, systemd always expands $
+				if (syntax == "tcsh" && strings.Contains(dir, "'")) || (syntax == "environment.d" && strings.Contains(dir, "$")) {
+					continue
+				}
+			}
+			if err != nil {
+				t.Errorf("%s: pathLine(%q) failed: %v", syntax, dir, err)
+				continue
+			}
+			if !setsPathTo(line, dir) {
+				t.Errorf("%s: line for %q isn't recognised: %s", syntax, dir, line)
+			}
+			if syntax != "environment.d" {
+				if _, err := manPathLine(syntax, dir); err != nil {
+					t.Errorf("%s: manPathLine(%q) failed: %v", syntax, dir, err)
+				}
+			}
+		}
+	}
+
+	for _, dir := range []string{"/opt/a:b", "/opt/a\nb"} {
+		if _, err := pathLine("bash", dir); !errors.Is(err, ErrUnsupportedDir) {
+			t.Errorf("Expected %q to be rejected, got %v", dir, err)
+		}
+	}
+}
+
+func TestInitScriptQuoting(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+
+	for _, dir := range []string{"/opt/my tools/bin", "/opt/it's/bin", "/opt/a\"$b`c`\\d/*[x]"} {
+		script, err := InitScript("sh", InitOptions{BinDirs: []string{dir}, ManDirs: []string{dir}})
+		if err != nil {
+			t.Fatalf("InitScript failed: %v", err)
+		}
+
+		// Evaluating the script twice adds dir once
+		cmd := exec.Command(sh, "-c", script+"\n"+script+"\nprintf '%s\\n' \"$PATH\" \"$MANPATH\"")
+		cmd.Env = []string{"PATH=/usr/bin:/bin", "MANPATH="}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("script failed for %q: %v\n%s", dir, err, script)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		expected := []string{dir + ":/usr/bin:/bin", dir + ":"}
+		if len(lines) != 2 || lines[0] != expected[0] || lines[1] != expected[1] {
+			t.Errorf("Expected %q, got %q", expected, lines)
+		}
+	}
+}
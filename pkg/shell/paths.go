@@ -0,0 +1,293 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// etcDir holds the system-wide shell configuration
+var etcDir = "/etc"
+
+// pathAddition finds where a config file line adds directories to PATH in
+// one of the supported syntaxes, and how to split the added value
+type pathAddition struct {
+	re *regexp.Regexp
+	// split splits the value matched by the last group into entries
+	split func(string) []string
+}
+
+// pathAdditions are the ways supported shells add to PATH. Conditions and
+// removals that merely mention a directory, such as
+// case ":$PATH:" in *:/dir:*) or PATH=${PATH//:\/dir/}, don't match, or
+// don't yield the directory as a whole entry.
+var pathAdditions = []pathAddition{
+	// sh, environment.d and /etc/environment: export PATH="/dir:$PATH"
+	{regexp.MustCompile(`(?:^|[\s;&|({])(?:(?:export|declare\s+-x|typeset\s+-x)\s+)?PATH=("(?:[^"\\]|\\.)*"|'[^']*'|[^\s;&|)]*)`), splitList},
+	// csh: setenv PATH "/dir:${PATH}"
+	{regexp.MustCompile(`\bsetenv\s+PATH\s+("(?:[^"\\]|\\.)*"|'[^']*'|\S+)`), splitList},
+	// csh and zsh arrays: set path = (/dir $path), path=(/dir $path)
+	{regexp.MustCompile(`(?:^|[\s;&|{])(?:set\s+)?path\s*\+?=\s*\(([^)]*)\)`), splitWords},
+	// fish: set -gx PATH /dir $PATH, fish_add_path /dir
+	{regexp.MustCompile(`\bset\s+(?:-[gxUpa]+\s+)*(?:PATH|fish_user_paths)\s+([^;]*)`), splitWords},
+	{regexp.MustCompile(`\bfish_add_path\s+([^;]*)`), splitWords},
+	// nu: $env.PATH = ($env.PATH | prepend '/dir')
+	{regexp.MustCompile(`\$env\.(?:PATH|Path)\b.*\|\s*(?:prepend|append)\s+(\[[^\]]*\]|'[^']*'|"(?:[^"\\]|\\.)*"|[^\s|)]+)`), splitWords},
+	// elvish: set paths = ['/dir' $@paths]
+	{regexp.MustCompile(`\bset\s+paths\s*=\s*\[((?:'(?:[^']|'')*'|[^\]'])*)\]`), splitWords},
+	// xonsh: $PATH.insert(0, '/dir'), $PATH.append('/dir')
+	{regexp.MustCompile(`\$PATH\.(?:insert\(\s*\d+\s*,|append\(|prepend\()\s*('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*")`), splitWords},
+	// pwsh: $env:PATH = '/dir' + [IO.Path]::PathSeparator + $env:PATH
+	{regexp.MustCompile(`(?i)\$env:PATH\s*=\s*(` + pwshTerm + `(?:\s*\+\s*` + pwshTerm + `)*)`), splitPwsh},
+}
+
+// setsPathTo reports whether a config file line adds the resolved directory
+// target to PATH. Comments are ignored.
+func setsPathTo(line, target string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return false
+	}
+	
+	for _, a := range pathAdditions {
+		for _, m := range a.re.FindAllStringSubmatch(line, -1) {
+			for _, entry := range a.split(m[len(m)-1]) {
+				entry = expandHome(entry)
+				if !filepath.IsAbs(entry) {
+					continue
+				}
+				if resolved, err := resolvePath(entry); err == nil && resolved == target {
+					return true
+				}
+			}
+		}
+	}
+	
+	return false
+}
+
+// splitList splits a colon-separated PATH value, which may be quoted
+func splitList(value string) []string {
+	return strings.Split(unquote(value), ":")
+}
+
+// splitWords splits a list of words, each of which may be quoted. Inside
+// single quotes, \' and '' stand for a quote, as fish, Python and elvish
+// write it. Options such as fish_add_path's --move are left out.
+func splitWords(value string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote == '\'' && c == '\\' && i+1 < len(value) && (value[i+1] == '\\' || value[i+1] == '\''):
+			i++
+			word.WriteByte(value[i])
+		case quote == '\'' && c == '\'' && i+1 < len(value) && value[i+1] == '\'':
+			i++
+			word.WriteByte(c)
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == '"' && c == '\\' && i+1 < len(value), quote == 0 && c == '\\' && i+1 < len(value):
+			i++
+			word.WriteByte(value[i])
+		case quote != 0:
+			word.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case strings.IndexByte(" \t,[]", c) >= 0:
+			if inWord && !strings.HasPrefix(word.String(), "-") {
+				words = append(words, word.String())
+			}
+			word.Reset()
+			inWord = false
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord && !strings.HasPrefix(word.String(), "-") {
+		words = append(words, word.String())
+	}
+	
+	return words
+}
+
+// splitPwsh returns the entries of the quoted strings in a PowerShell
+// expression
+func splitPwsh(value string) []string {
+	var entries []string
+	for _, s := range pwshString.FindAllString(value, -1) {
+		if s[0] == '\'' {
+			s = strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		} else {
+			s = pwshEscape.ReplaceAllString(s[1:len(s)-1], "$1")
+		}
+		entries = append(entries, filepath.SplitList(s)...)
+	}
+	return entries
+}
+
+// pwshEscape matches a backtick escape in a double-quoted PowerShell string
+var pwshEscape = regexp.MustCompile("`(.)")
+
+// pwshQuoted matches a quoted PowerShell string
+const pwshQuoted = `'(?:[^']|'')*'|"(?:[^"\x60]|\x60.)*"`
+
+// pwshTerm matches a term of a PATH value joined with +: a string, the old
+// PATH or the separator
+const pwshTerm = `(?:` + pwshQuoted + `|(?i:\$env:PATH)|\[(?i:(?:System\.)?IO\.Path)\]::PathSeparator)`
+
+// pwshString matches a quoted PowerShell string
+var pwshString = regexp.MustCompile(pwshQuoted)
+
+// unquote removes the quotes around a shell word and the escapes inside
+// double quotes
+func unquote(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	switch {
+	case s[0] == '\'' && s[len(s)-1] == '\'':
+		return s[1 : len(s)-1]
+	case s[0] == '"' && s[len(s)-1] == '"':
+		s = s[1 : len(s)-1]
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			b.WriteByte(s[i])
+		}
+		return b.String()
+	}
+	return s
+}
+
+// expandHome replaces a leading ~ and any $HOME or ${HOME} with the home
+// directory
+func expandHome(s string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return s
+	}
+	
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		s = home + s[1:]
+	}
+	s = strings.ReplaceAll(s, "${HOME}", home)
+	s = strings.ReplaceAll(s, "$HOME", home)
+	
+	return s
+}
+
+// resolvePath returns the absolute form of path with symlinks resolved. Parts
+// of the path that don't exist yet are kept as they are, so a destination
+// that will only be created on install still compares equal to its PATH entry.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	
+	var missing []string
+	existing := abs
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			parts := append([]string{resolved}, missing...)
+			return filepath.Join(parts...), nil
+		}
+		
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+}
+
+// sessionPathFiles returns the system-wide files that set PATH for every
+// session, whatever the shell
+func sessionPathFiles() []string {
+	return []string{
+		filepath.Join(etcDir, "environment"),
+		filepath.Join(etcDir, "login.defs"),
+	}
+}
+
+// alsoRead returns the system-wide files read by the same sessions as f, so
+// that a line in one of them adding a directory to PATH stands in for f
+func alsoRead(f configFile) []string {
+	switch f.syntax {
+	case "sh":
+		scripts, _ := filepath.Glob(filepath.Join(etcDir, "profile.d", "*.sh"))
+		return append([]string{filepath.Join(etcDir, "profile")}, scripts...)
+	case "bash":
+		return []string{filepath.Join(etcDir, "bash.bashrc"), filepath.Join(etcDir, "bashrc")}
+	case "zsh":
+		return []string{filepath.Join(etcDir, "zshenv"), filepath.Join(etcDir, "zsh", "zshenv")}
+	default:
+		return nil
+	}
+}
+
+// addsPath reports whether a line in any of the files adds the resolved
+// directory target to PATH. Files that don't exist or can't be read are
+// skipped.
+func addsPath(paths []string, target string) (bool, error) {
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				continue
+			}
+			return false, err
+		}
+		
+		for _, line := range strings.Split(string(content), "\n") {
+			if setsPathTo(line, target) {
+				return true, nil
+			}
+		}
+	}
+	
+	return false, nil
+}
+
+// listsPath reports whether macOS' path_helper lists the resolved directory
+// target. Its files list one directory per line.
+func listsPath(target string) bool {
+	for _, path := range systemPathLists() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		
+		for _, line := range strings.Split(string(content), "\n") {
+			entry := strings.TrimSpace(line)
+			if !filepath.IsAbs(entry) {
+				continue
+			}
+			if resolved, err := resolvePath(entry); err == nil && resolved == target {
+				return true
+			}
+		}
+	}
+	
+	return false
+}
+
+// systemPathLists returns the files macOS' path_helper builds PATH from
+func systemPathLists() []string {
+	files := []string{filepath.Join(etcDir, "paths")}
+	
+	entries, _ := filepath.Glob(filepath.Join(etcDir, "paths.d", "*"))
+	return append(files, entries...)
+}
@@ -0,0 +1,235 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsInPathEntries(t *testing.T) {
+	home := setTestHome(t)
+
+	bin := filepath.Join(home, ".local", "bin")
+	if err := os.MkdirAll(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A symlinked home, like /home -> /var/home on Fedora Silverblue
+	link := filepath.Join(t.TempDir(), "home")
+	if err := os.Symlink(home, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		dir      string
+		expected bool
+	}{
+		{"Tilde entry", "/usr/bin:~/.local/bin", bin, true},
+		{"HOME entry", "/usr/bin:$HOME/.local/bin", bin, true},
+		{"Braced HOME entry", "${HOME}/.local/bin:/usr/bin", bin, true},
+		{"Symlinked entry", "/usr/bin:" + filepath.Join(link, ".local", "bin"), bin, true},
+		{"Symlinked dir", "/usr/bin:" + bin, filepath.Join(link, ".local", "bin"), true},
+		{"Trailing slash", "/usr/bin:" + bin + "/", bin, true},
+		{"Missing dir", "/usr/bin:" + filepath.Join(home, "opt", "bin"), filepath.Join(home, "opt", "bin"), true},
+		{"Empty entry", "/usr/bin::/bin", bin, false},
+		{"Relative entry", "/usr/bin:.local/bin", bin, false},
+		{"Not present", "/usr/bin:/bin", bin, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PATH", tt.path)
+
+			// The working directory must not make empty or relative entries match
+			original, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chdir(home); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(original)
+
+			result, err := IsInPath(tt.dir)
+			if err != nil {
+				t.Fatalf("IsInPath failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("IsInPath(%q) with PATH=%q = %v; want %v", tt.dir, tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsConfigured(t *testing.T) {
+	originalGOOS := goos
+	defer func() { goos = originalGOOS }()
+	goos = "linux"
+
+	const envd = ".config/environment.d/50-bii.conf"
+	tests := []struct {
+		name  string
+		shell string
+		// files are written to the home directory, or to etc if they start
+		// with /etc/
+		files map[string]string
+		// missing are the files that don't add the directory yet
+		missing []string
+	}{
+		{"nothing", "bash", nil, []string{".bashrc", ".profile", envd}},
+		{"bash export", "bash", map[string]string{".bashrc": "export PATH=\"$HOME/.local/bin:$PATH\"\n"}, []string{".profile", envd}},
+		{"bash login file", "bash", map[string]string{".profile": "PATH=~/.local/bin:$PATH\n"}, []string{".bashrc", envd}},
+		{"conditional assignment", "sh", map[string]string{".profile": "[ -d \"$HOME/.local/bin\" ] && PATH=\"$HOME/.local/bin:$PATH\"\n"}, []string{envd}},
+		{"every file", "bash", map[string]string{
+			".bashrc":  "export PATH=\"$HOME/.local/bin:$PATH\"\n",
+			".profile": "PATH=~/.local/bin:$PATH\n",
+			envd:       "PATH=${HOME}/.local/bin:${PATH}\n",
+		}, nil},
+		{"commented out", "bash", map[string]string{".bashrc": "# export PATH=\"$HOME/.local/bin:$PATH\"\n"}, []string{".bashrc", ".profile", envd}},
+		{"other variable", "bash", map[string]string{".bashrc": "export MANPATH=\"$HOME/.local/bin:$MANPATH\"\n"}, []string{".bashrc", ".profile", envd}},
+		{"other directory", "bash", map[string]string{".bashrc": "export PATH=\"$HOME/.local/bin/extra:$PATH\"\n"}, []string{".bashrc", ".profile", envd}},
+		{"condition only", "bash", map[string]string{".bashrc": "if [[ \":$PATH:\" != *\":$HOME/.local/bin:\"* ]]; then echo missing; fi\n"}, []string{".bashrc", ".profile", envd}},
+		{"removal", "bash", map[string]string{".bashrc": "PATH=$(echo \"$PATH\" | sed \"s|$HOME/.local/bin:||\")\n"}, []string{".bashrc", ".profile", envd}},
+		{"fish erase", "fish", map[string]string{".config/fish/conf.d/bii.fish": "set -e fish_user_paths ~/.local/bin\n"}, []string{".config/fish/conf.d/bii.fish", envd}},
+		{"zsh path array", "zsh", map[string]string{".zshenv": "path=(~/.local/bin $path)\n"}, []string{envd}},
+		{"zsh interactive file", "zsh", map[string]string{".zshrc": "path=(~/.local/bin $path)\n"}, []string{".zshenv", envd}},
+		{"fish_add_path", "fish", map[string]string{".config/fish/conf.d/bii.fish": "fish_add_path --move ~/.local/bin\n"}, []string{envd}},
+		{"login profile", "bash", map[string]string{"/etc/profile.d/local.sh": "export PATH=\"$HOME/.local/bin:$PATH\"\n"}, []string{".bashrc", envd}},
+		{"every session", "bash", map[string]string{"/etc/environment": "PATH=\"$HOME/.local/bin:/usr/bin\"\n"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := setTestHome(t)
+			etc := setTestEtc(t)
+			bin := filepath.Join(home, ".local", "bin")
+
+			for name, content := range tt.files {
+				path := filepath.Join(home, name)
+				if rest, ok := strings.CutPrefix(name, "/etc/"); ok {
+					path = filepath.Join(etc, rest)
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			missing, err := unconfiguredFiles(tt.shell, bin)
+			if err != nil {
+				t.Fatalf("unconfiguredFiles failed: %v", err)
+			}
+			var got []string
+			for _, f := range missing {
+				rel, _ := filepath.Rel(home, f.path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.missing) {
+				t.Errorf("Expected %v to be missing, got %v", tt.missing, got)
+			}
+
+			ok, err := IsConfigured(tt.shell, bin)
+			if err != nil || ok != (len(tt.missing) == 0) {
+				t.Errorf("IsConfigured = %v, %v", ok, err)
+			}
+
+			// Only the missing files are written
+			changes, err := PlanAddToPath(tt.shell, bin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != len(tt.missing) {
+				t.Errorf("Expected %d changes, got %d", len(tt.missing), len(changes))
+			}
+		})
+	}
+
+	// Lines written by AddToPath are recognised
+	home := setTestHome(t)
+	bin := filepath.Join(home, ".local", "bin")
+	if _, err := AddToPath("bash", bin); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := IsConfigured("bash", bin); err != nil || !ok {
+		t.Errorf("Expected AddToPath result to be configured, got %v (%v)", ok, err)
+	}
+}
+
+func TestSetsPathTo(t *testing.T) {
+	home := setTestHome(t)
+	bin := filepath.Join(home, ".local", "bin")
+
+	tests := []struct {
+		line     string
+		expected bool
+	}{
+		{`export PATH="$HOME/.local/bin:$PATH"`, true},
+		{`PATH=$PATH:~/.local/bin`, true},
+		{`case ":$PATH:" in *":` + bin + `:"*) ;; *) export PATH="` + bin + `:$PATH" ;; esac`, true},
+		{`case ":$PATH:" in *":` + bin + `:"*) echo yes ;; esac`, false},
+		{`export PATH=${PATH//:$HOME\/.local\/bin/}`, false},
+		{`if ( ":${PATH}:" !~ *:` + bin + `:* ) setenv PATH "` + bin + `:${PATH}"`, true},
+		{`set path = ( ~/.local/bin $path )`, true},
+		{`contains ` + bin + ` $PATH; or set -gx PATH ` + bin + ` $PATH`, true},
+		{`contains ` + bin + ` $PATH; and echo yes`, false},
+		{`$env.PATH = ($env.PATH | split row (char esep) | prepend '` + bin + `' | uniq)`, true},
+		{`if (not (has-value $paths '` + bin + `')) { set paths = ['` + bin + `' $@paths] }`, true},
+		{`if '` + bin + `' not in $PATH: $PATH.insert(0, '` + bin + `')`, true},
+		{`if '` + bin + `' in $PATH: $PATH.remove('` + bin + `')`, false},
+		{`$env:PATH = '` + bin + `' + [IO.Path]::PathSeparator + $env:PATH`, true},
+		{`$env:PATH = ($env:PATH -split ':' | Where-Object { $_ -ne '` + bin + `' }) -join ':'`, false},
+	}
+
+	for _, tt := range tests {
+		if got := setsPathTo(tt.line, bin); got != tt.expected {
+			t.Errorf("setsPathTo(%q) = %v; want %v", tt.line, got, tt.expected)
+		}
+	}
+}
+
+func TestIsConfiguredSystemFiles(t *testing.T) {
+	etc := setTestEtc(t)
+	setTestHome(t)
+
+	files := map[string]string{
+		"environment": "PATH=\"/usr/local/sbin:/usr/local/bin:/usr/bin\"\n",
+		"paths.d/go":  "/usr/local/go/bin\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(etc, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, dir := range []string{"/usr/local/bin", "/usr/local/go/bin"} {
+		if ok, err := IsConfigured("bash", dir); err != nil || !ok {
+			t.Errorf("Expected %s to be configured system-wide, got %v (%v)", dir, ok, err)
+		}
+	}
+
+	if ok, _ := IsConfigured("bash", "/opt/other/bin"); ok {
+		t.Error("Expected /opt/other/bin not to be configured")
+	}
+}
+
+// setTestEtc points etcDir at an empty temporary directory
+func setTestEtc(t *testing.T) string {
+	t.Helper()
+
+	etc := t.TempDir()
+	original := etcDir
+	etcDir = etc
+	t.Cleanup(func() { etcDir = original })
+
+	return etc
+}
@@ -1,6 +1,7 @@
 package shell
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,32 +9,59 @@ import (
 	"strings"
 )
 
-// DetectShell detects the current user's shell
+var (
+	// ErrUnsupportedShell is returned for shells bii can't configure
+	ErrUnsupportedShell = errors.New("unsupported shell")
+	// ErrNoShell is returned when the shell in use can't be detected
+	ErrNoShell = errors.New("could not detect shell")
+	// ErrUnsupportedDir is returned for directories that can't be written
+	// to a config file as a PATH entry
+	ErrUnsupportedDir = errors.New("directory can't be added to PATH")
+)
+
+// DetectShell detects the shell bii is being run from. It looks for a shell
+// among the parent processes first, since $SHELL is the login shell and not
+// necessarily the one in use, then falls back to $SHELL and the user's
+// passwd entry.
 func DetectShell() (string, error) {
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		return "", fmt.Errorf("SHELL environment variable not set")
+	if shell := parentShell(os.Getppid()); shell != "" {
+		return shell, nil
+	}
+	
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return filepath.Base(shell), nil
 	}
 	
-	return filepath.Base(shell), nil
+	if shell := passwdShell(os.Getuid()); shell != "" {
+		return shell, nil
+	}
+	
+	return "", fmt.Errorf("%w: no shell parent process, SHELL not set and no passwd entry", ErrNoShell)
 }
 
-// IsInPath checks if a directory is in the current PATH
+// IsInPath checks if a directory is in the current PATH. Entries are compared
+// after expanding ~ and $HOME and resolving symlinks; empty and relative
+// entries depend on the working directory and never match.
 func IsInPath(dir string) (bool, error) {
 	path := os.Getenv("PATH")
-	dirs := strings.Split(path, ":")
+	dirs := filepath.SplitList(path)
 	
-	absDir, err := filepath.Abs(dir)
+	target, err := resolvePath(dir)
 	if err != nil {
 		return false, err
 	}
 	
 	for _, p := range dirs {
-		absPath, err := filepath.Abs(p)
+		p = expandHome(p)
+		if p == "" || !filepath.IsAbs(p) {
+			continue
+		}
+		
+		resolved, err := resolvePath(p)
 		if err != nil {
 			continue
 		}
-		if absPath == absDir {
+		if resolved == target {
 			return true, nil
 		}
 	}
@@ -41,56 +69,192 @@ func IsInPath(dir string) (bool, error) {
 	return false, nil
 }
 
-// AddToPath adds a directory to the PATH in the appropriate shell config file
-func AddToPath(shell, dir string) error {
-	home, err := os.UserHomeDir()
+// IsConfigured reports whether new shells and sessions already get dir in
+// PATH, so that they will have it even if the current environment doesn't:
+// either system-wide files add it for every session, or each file AddToPath
+// writes, or a system-wide file read by the same sessions, adds it through
+// bii's block or a line someone wrote.
+func IsConfigured(shell, dir string) (bool, error) {
+	missing, err := unconfiguredFiles(shell, dir)
 	if err != nil {
-		return err
+		return false, err
+	}
+	return len(missing) == 0, nil
+}
+
+// unconfiguredFiles returns the files AddToPath writes for shell that don't
+// add dir to PATH yet, as IsConfigured decides it
+func unconfiguredFiles(shell, dir string) ([]configFile, error) {
+	files, err := pathConfigFiles(shell)
+	if err != nil {
+		return nil, err
 	}
 	
-	var configFile string
-	var exportLine string
+	target, err := resolvePath(dir)
+	if err != nil {
+		return nil, err
+	}
 	
-	switch shell {
-	case "bash":
-		configFile = filepath.Join(home, ".bashrc")
-		exportLine = fmt.Sprintf("\n# Added by bii\nexport PATH=\"%s:$PATH\"\n", dir)
-	case "zsh":
-		configFile = filepath.Join(home, ".zshrc")
-		exportLine = fmt.Sprintf("\n# Added by bii\nexport PATH=\"%s:$PATH\"\n", dir)
-	case "fish":
-		configFile = filepath.Join(home, ".config", "fish", "config.fish")
-		exportLine = fmt.Sprintf("\n# Added by bii\nset -gx PATH %s $PATH\n", dir)
-		// Ensure fish config directory exists
-		if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
-			return err
+	everywhere, err := addsPath(sessionPathFiles(), target)
+	if err != nil {
+		return nil, err
+	}
+	if everywhere || listsPath(target) {
+		return nil, nil
+	}
+	
+	var missing []configFile
+	for _, f := range files {
+		ok, err := addsPath(append([]string{f.path}, alsoRead(f)...), target)
+		if err != nil {
+			return nil, err
 		}
-	default:
-		return fmt.Errorf("unsupported shell: %s", shell)
+		if !ok {
+			missing = append(missing, f)
+		}
+	}
+	
+	return missing, nil
+}
+
+// AddToPath adds a directory to the PATH in the config files of the given
+// shell and returns the files it changed. The change is kept in a block
+// delimited by bii markers so it can be updated or removed later.
+func AddToPath(shell, dir string) ([]string, error) {
+	changes, err := PlanAddToPath(shell, dir)
+	if err != nil {
+		return nil, err
 	}
 	
-	// Check if already added
-	content, err := os.ReadFile(configFile)
-	if err != nil && !os.IsNotExist(err) {
-		return err
+	var changed []string
+	for _, c := range changes {
+		if err := c.apply(); err != nil {
+			return changed, err
+		}
+		changed = append(changed, c.Path)
 	}
 	
-	if strings.Contains(string(content), dir) {
-		return nil // Already added
+	return changed, nil
+}
+
+// PlanAddToPath returns the changes AddToPath would make without writing
+// anything. Files that already add dir to PATH are left alone.
+func PlanAddToPath(shell, dir string) ([]Change, error) {
+	files, err := unconfiguredFiles(shell, dir)
+	if err != nil {
+		return nil, err
 	}
 	
-	// Append to config file
-	f, err := os.OpenFile(configFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	var changes []Change
+	for _, f := range files {
+		line, err := pathLine(f.syntax, dir)
+		if err != nil {
+			return nil, err
+		}
+		
+		change, err := planBlock(f.path, func(lines []string) []string {
+			return appendUnique(lines, line)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	
+	return changes, nil
+}
+
+// RemoveFromPath removes a directory previously added by AddToPath and
+// returns the files it changed
+func RemoveFromPath(shell, dir string) ([]string, error) {
+	files, err := pathConfigFiles(shell)
+	if err != nil {
+		return nil, err
+	}
+	
+	rc, err := GetShellConfigPath(shell)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
+	// Older versions wrote to the interactive config file only
+	files = append(files, configFile{rc, shell})
 	
-	if _, err := f.WriteString(exportLine); err != nil {
-		return err
+	var changed []string
+	for _, f := range files {
+		line, err := pathLine(f.syntax, dir)
+		if err != nil {
+			return changed, err
+		}
+		legacy := legacyPathLine(f.syntax, dir)
+		
+		ok, err := updateBlock(f.path, func(lines []string) []string {
+			return removeLine(removeLine(lines, line), legacy)
+		})
+		if err != nil {
+			return changed, err
+		}
+		if ok {
+			changed = appendUnique(changed, f.path)
+		}
 	}
 	
-	return nil
+	return changed, nil
+}
+
+// RemoveBlock removes everything bii added to the shell's config files and
+// returns the files it changed
+func RemoveBlock(shell string) ([]string, error) {
+	paths, err := managedFiles(shell)
+	if err != nil {
+		return nil, err
+	}
+	
+	var changed []string
+	for _, path := range paths {
+		ok, err := updateBlock(path, func(lines []string) []string {
+			return nil
+		})
+		if err != nil {
+			return changed, err
+		}
+		if ok {
+			changed = append(changed, path)
+		}
+	}
+	
+	return changed, nil
+}
+
+// ManagedLines returns the lines bii manages in each of the shell's config
+// files. Files without a bii block are omitted.
+func ManagedLines(shell string) (map[string][]string, error) {
+	paths, err := managedFiles(shell)
+	if err != nil {
+		return nil, err
+	}
+	
+	managed := make(map[string][]string)
+	for _, path := range paths {
+		lines, err := blockLines(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) > 0 {
+			managed[path] = lines
+		}
+	}
+	
+	return managed, nil
+}
+
+// legacyPathLine returns the line older versions of bii wrote for dir
+func legacyPathLine(syntax, dir string) string {
+	if syntax == "fish" {
+		return fmt.Sprintf("set -gx PATH %s $PATH", dir)
+	}
+	return fmt.Sprintf("export PATH=\"%s:$PATH\"", dir)
 }
 
 // GetShellConfigPath returns the config file path for the given shell
@@ -104,11 +268,29 @@ func GetShellConfigPath(shell string) (string, error) {
 	case "bash":
 		return filepath.Join(home, ".bashrc"), nil
 	case "zsh":
-		return filepath.Join(home, ".zshrc"), nil
+		return filepath.Join(zshDir(home), ".zshrc"), nil
 	case "fish":
-		return filepath.Join(home, ".config", "fish", "config.fish"), nil
+		return filepath.Join(configHome(home), "fish", "config.fish"), nil
+	case "nu":
+		return filepath.Join(nuConfigDir(home), "config.nu"), nil
+	case "elvish":
+		return filepath.Join(configHome(home), "elvish", "rc.elv"), nil
+	case "xonsh":
+		return filepath.Join(home, ".xonshrc"), nil
+	case "tcsh":
+		return tcshConfigFile(home), nil
+	case "csh":
+		return filepath.Join(home, ".cshrc"), nil
+	case "ksh":
+		return filepath.Join(home, ".kshrc"), nil
+	case "mksh":
+		return filepath.Join(home, ".mkshrc"), nil
+	case "sh", "dash":
+		return filepath.Join(home, ".profile"), nil
+	case "pwsh":
+		return filepath.Join(configHome(home), "powershell", "Microsoft.PowerShell_profile.ps1"), nil
 	default:
-		return "", fmt.Errorf("unsupported shell: %s", shell)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedShell, shell)
 	}
 }
 
@@ -117,3 +299,87 @@ func TestCommand(cmd string) bool {
 	_, err := exec.LookPath(cmd)
 	return err == nil
 }
+
+// InstallCompletion writes a completion script for the given shell and makes
+// the shell config file load it. It returns the path of the written script.
+func InstallCompletion(shell string, script []byte) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	
+	configFile, err := GetShellConfigPath(shell)
+	if err != nil {
+		return "", err
+	}
+	
+	var scriptPath string
+	var sourceLine string
+	
+	switch shell {
+	case "bash", "zsh":
+		scriptPath = filepath.Join(home, ".local", "share", "bii", "completions", "bii."+shell)
+		sourceLine = fmt.Sprintf("[ -f \"%s\" ] && source \"%s\"", scriptPath, scriptPath)
+	case "fish":
+		// fish loads completions from this directory on its own
+		scriptPath = filepath.Join(configHome(home), "fish", "completions", "bii.fish")
+	default:
+		return "", fmt.Errorf("%w for completion: %s", ErrUnsupportedShell, shell)
+	}
+	
+	if err := os.MkdirAll(filepath.Dir(scriptPath), 0755); err != nil {
+		return "", err
+	}
+	
+	if err := os.WriteFile(scriptPath, script, 0644); err != nil {
+		return "", err
+	}
+	
+	if sourceLine == "" {
+		return scriptPath, nil
+	}
+	
+	_, err = updateBlock(configFile, func(lines []string) []string {
+		return appendUnique(lines, sourceLine)
+	})
+	if err != nil {
+		return "", err
+	}
+	
+	return scriptPath, nil
+}
+
+// PlanAddToSystemPath returns the change that adds dir to PATH for all
+// users, or nil if it is already there. Login shells pick up
+// /etc/profile.d/bii.sh; on macOS path_helper reads /etc/paths.d/bii. The
+// caller writes the change, since it usually needs root.
+func PlanAddToSystemPath(dir string) (*Change, error) {
+	if goos == "darwin" {
+		path := filepath.Join(etcDir, "paths.d", "bii")
+		
+		content, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		
+		lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+		if len(content) == 0 {
+			lines = nil
+		}
+		updated := appendUnique(lines, dir)
+		if len(updated) == len(lines) {
+			return nil, nil
+		}
+		
+		return &Change{Path: path, Before: string(content), After: strings.Join(updated, "\n") + "\n"}, nil
+	}
+	
+	line, err := pathLine("sh", dir)
+	if err != nil {
+		return nil, err
+	}
+	
+	return planBlock(filepath.Join(etcDir, "profile.d", "bii.sh"), func(lines []string) []string {
+		return appendUnique(lines, line)
+	})
+}
@@ -8,6 +8,9 @@ import (
 )
 
 func TestDetectShell(t *testing.T) {
+	// No shell among the parent processes, so SHELL decides
+	setFakeProc(t)
+
 	// Save original SHELL
 	originalShell := os.Getenv("SHELL")
 	defer os.Setenv("SHELL", originalShell)
@@ -65,6 +68,9 @@ func TestIsInPath(t *testing.T) {
 }
 
 func TestGetShellConfigPath(t *testing.T) {
+	t.Setenv("ZDOTDIR", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		t.Skip("Cannot get home directory")
@@ -77,6 +83,12 @@ func TestGetShellConfigPath(t *testing.T) {
 		{"bash", filepath.Join(home, ".bashrc")},
 		{"zsh", filepath.Join(home, ".zshrc")},
 		{"fish", filepath.Join(home, ".config", "fish", "config.fish")},
+		{"elvish", filepath.Join(home, ".config", "elvish", "rc.elv")},
+		{"xonsh", filepath.Join(home, ".xonshrc")},
+		{"csh", filepath.Join(home, ".cshrc")},
+		{"ksh", filepath.Join(home, ".kshrc")},
+		{"sh", filepath.Join(home, ".profile")},
+		{"pwsh", filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")},
 	}
 
 	for _, tt := range tests {
@@ -99,63 +111,393 @@ func TestGetShellConfigPath(t *testing.T) {
 }
 
 func TestAddToPath(t *testing.T) {
-	// Create temporary home directory
-	tmpDir := t.TempDir()
-	originalHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", originalHome)
+	originalGOOS := goos
+	defer func() { goos = originalGOOS }()
 
 	testDir := "/test/bin"
 
 	tests := []struct {
 		shell          string
-		configFile     string
+		goos           string
+		configFiles    []string
 		expectedSubstr string
 	}{
-		{"bash", ".bashrc", "export PATH=\"" + testDir + ":$PATH\""},
-		{"zsh", ".zshrc", "export PATH=\"" + testDir + ":$PATH\""},
-		{"fish", ".config/fish/config.fish", "set -gx PATH " + testDir + " $PATH"},
+		{"bash", "linux", []string{".bashrc", ".profile", ".config/environment.d/50-bii.conf"}, "export PATH=\"" + testDir + ":$PATH\""},
+		{"bash", "darwin", []string{".bashrc", ".bash_profile"}, "export PATH=\"" + testDir + ":$PATH\""},
+		{"zsh", "linux", []string{".zshenv", ".config/environment.d/50-bii.conf"}, "export PATH=\"" + testDir + ":$PATH\""},
+		{"zsh", "darwin", []string{".zshenv"}, "export PATH=\"" + testDir + ":$PATH\""},
+		{"fish", "darwin", []string{".config/fish/conf.d/bii.fish"}, "set -gx PATH " + testDir + " $PATH"},
+		{"nu", "linux", []string{".config/nushell/env.nu", ".config/environment.d/50-bii.conf"}, "prepend '" + testDir + "'"},
+		{"nu", "darwin", []string{"Library/Application Support/nushell/env.nu"}, "prepend '" + testDir + "'"},
+		{"elvish", "darwin", []string{".config/elvish/rc.elv"}, "set paths = ['" + testDir + "' $@paths]"},
+		{"xonsh", "darwin", []string{".xonshrc"}, "$PATH.insert(0, '" + testDir + "')"},
+		{"tcsh", "darwin", []string{".tcshrc"}, "setenv PATH \"" + testDir + ":${PATH}\""},
+		{"csh", "darwin", []string{".cshrc"}, "setenv PATH \"" + testDir + ":${PATH}\""},
+		{"ksh", "darwin", []string{".kshrc", ".profile"}, "export PATH=\"" + testDir + ":$PATH\""},
+		{"mksh", "darwin", []string{".mkshrc", ".profile"}, "export PATH=\"" + testDir + ":$PATH\""},
+		{"sh", "darwin", []string{".profile"}, "export PATH=\"" + testDir + ":$PATH\""},
+		{"pwsh", "linux", []string{".config/powershell/Microsoft.PowerShell_profile.ps1", ".config/environment.d/50-bii.conf"}, "$env:PATH = '" + testDir + "'"},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.shell, func(t *testing.T) {
+		t.Run(tt.shell+"/"+tt.goos, func(t *testing.T) {
 			// Create a fresh temp dir for each test
-			shellTmpDir := t.TempDir()
-			os.Setenv("HOME", shellTmpDir)
+			shellTmpDir := setTestHome(t)
+			goos = tt.goos
 
-			err := AddToPath(tt.shell, testDir)
+			changed, err := AddToPath(tt.shell, testDir)
 			if err != nil {
 				t.Fatalf("AddToPath failed: %v", err)
 			}
 
-			// Verify config file was created and contains the PATH
-			configPath := filepath.Join(shellTmpDir, tt.configFile)
-			content, err := os.ReadFile(configPath)
-			if err != nil {
-				t.Fatalf("Failed to read config file: %v", err)
+			if len(changed) != len(tt.configFiles) {
+				t.Errorf("Expected %d changed files, got %v", len(tt.configFiles), changed)
 			}
 
-			if !strings.Contains(string(content), tt.expectedSubstr) {
-				t.Errorf("Config file does not contain expected PATH export.\nExpected substring: %s\nGot: %s",
-					tt.expectedSubstr, string(content))
+			for i, name := range tt.configFiles {
+				// Verify config file was created and contains the PATH
+				configPath := filepath.Join(shellTmpDir, name)
+				if i < len(changed) && changed[i] != configPath {
+					t.Errorf("Expected %s to be reported as changed, got %s", configPath, changed[i])
+				}
+
+				content, err := os.ReadFile(configPath)
+				if err != nil {
+					t.Fatalf("Failed to read config file: %v", err)
+				}
+
+				if i == 0 && !strings.Contains(string(content), tt.expectedSubstr) {
+					t.Errorf("Config file does not contain expected PATH export.\nExpected substring: %s\nGot: %s",
+						tt.expectedSubstr, string(content))
+				}
 			}
 
 			// Test that running again doesn't duplicate
-			err = AddToPath(tt.shell, testDir)
+			changed, err = AddToPath(tt.shell, testDir)
 			if err != nil {
 				t.Fatalf("Second AddToPath failed: %v", err)
 			}
+			if len(changed) != 0 {
+				t.Errorf("Expected no changes on second run, got %v", changed)
+			}
 
-			content2, err := os.ReadFile(configPath)
+			managed, err := ManagedLines(tt.shell)
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			// Count occurrences of testDir
-			count := strings.Count(string(content2), testDir)
+			for file, lines := range managed {
+				if len(lines) != 1 {
+					t.Errorf("Expected one managed line in %s, got %v", file, lines)
+				}
+			}
+		})
+	}
+}
+
+func TestAddToPathBashLoginFile(t *testing.T) {
+	home := setTestHome(t)
+
+	// bash reads only the first login file that exists
+	profile := filepath.Join(home, ".bash_login")
+	if err := os.WriteFile(profile, []byte("# login\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := AddToPath("bash", "/test/bin")
+	if err != nil {
+		t.Fatalf("AddToPath failed: %v", err)
+	}
+
+	found := false
+	for _, path := range changed {
+		if path == profile {
+			found = true
+		}
+		if filepath.Base(path) == ".profile" || filepath.Base(path) == ".bash_profile" {
+			t.Errorf("Unexpected write to %s", path)
+		}
+	}
+	if !found {
+		t.Errorf("Expected %s to be changed, got %v", profile, changed)
+	}
+}
+
+func TestAddToPathTcshFallsBackToCshrc(t *testing.T) {
+	home := setTestHome(t)
+
+	cshrc := filepath.Join(home, ".cshrc")
+	if err := os.WriteFile(cshrc, []byte("# csh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := AddToPath("tcsh", "/test/bin")
+	if err != nil {
+		t.Fatalf("AddToPath failed: %v", err)
+	}
+	if len(changed) == 0 || changed[0] != cshrc {
+		t.Errorf("Expected %s to be changed, got %v", cshrc, changed)
+	}
+}
+
+func TestAddToPathZdotdir(t *testing.T) {
+	setTestHome(t)
+	zdotdir := t.TempDir()
+	t.Setenv("ZDOTDIR", zdotdir)
+
+	if _, err := AddToPath("zsh", "/test/bin"); err != nil {
+		t.Fatalf("AddToPath failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(zdotdir, ".zshenv")); err != nil {
+		t.Errorf("Expected .zshenv in ZDOTDIR: %v", err)
+	}
+}
+
+// setTestHome points HOME at a temporary directory and clears the variables
+// that would move config files out of it
+func setTestHome(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ZDOTDIR", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	return home
+}
+
+func TestInstallCompletion(t *testing.T) {
+	tests := []struct {
+		shell      string
+		scriptFile string
+		configFile string
+	}{
+		{"bash", ".local/share/bii/completions/bii.bash", ".bashrc"},
+		{"zsh", ".local/share/bii/completions/bii.zsh", ".zshrc"},
+		{"fish", ".config/fish/completions/bii.fish", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			tmpDir := setTestHome(t)
+
+			for i := 0; i < 2; i++ {
+				scriptPath, err := InstallCompletion(tt.shell, []byte("# completion"))
+				if err != nil {
+					t.Fatalf("InstallCompletion failed: %v", err)
+				}
+				if scriptPath != filepath.Join(tmpDir, tt.scriptFile) {
+					t.Errorf("Expected script at %s, got %s", tt.scriptFile, scriptPath)
+				}
+			}
+
+			if _, err := os.Stat(filepath.Join(tmpDir, tt.scriptFile)); err != nil {
+				t.Fatalf("Completion script not written: %v", err)
+			}
+
+			if tt.configFile == "" {
+				return
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, tt.configFile))
+			if err != nil {
+				t.Fatalf("Failed to read config file: %v", err)
+			}
+
+			count := strings.Count(string(content), "source \""+filepath.Join(tmpDir, tt.scriptFile))
 			if count != 1 {
-				t.Errorf("Expected testDir to appear once, but appeared %d times", count)
+				t.Errorf("Expected completion to be sourced once, got %d", count)
 			}
 		})
 	}
 }
+
+func TestRemoveFromPath(t *testing.T) {
+	originalGOOS := goos
+	defer func() { goos = originalGOOS }()
+	goos = "linux"
+
+	tmpDir := setTestHome(t)
+
+	configPath := filepath.Join(tmpDir, ".bashrc")
+	if err := os.WriteFile(configPath, []byte("# my config\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AddToPath("bash", "/first/bin"); err != nil {
+		t.Fatalf("AddToPath failed: %v", err)
+	}
+	if _, err := AddToPath("bash", "/second/bin"); err != nil {
+		t.Fatalf("AddToPath failed: %v", err)
+	}
+
+	if _, err := RemoveFromPath("bash", "/first/bin"); err != nil {
+		t.Fatalf("RemoveFromPath failed: %v", err)
+	}
+
+	managed, err := ManagedLines("bash")
+	if err != nil {
+		t.Fatalf("ManagedLines failed: %v", err)
+	}
+	lines := managed[configPath]
+	if len(lines) != 1 || !strings.Contains(lines[0], "/second/bin") {
+		t.Errorf("Expected only /second/bin to remain, got %v", lines)
+	}
+
+	changed, err := RemoveBlock("bash")
+	if err != nil {
+		t.Fatalf("RemoveBlock failed: %v", err)
+	}
+	if len(changed) == 0 {
+		t.Error("Expected RemoveBlock to report changed files")
+	}
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# my config\n" {
+		t.Errorf("Expected managed block to be removed, got %q", string(content))
+	}
+
+	// The user's files are emptied, bii's own files deleted
+	content, err = os.ReadFile(filepath.Join(tmpDir, ".profile"))
+	if err != nil || len(content) != 0 {
+		t.Errorf("Expected .profile to be kept empty, got %q (%v)", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".config", "environment.d", "50-bii.conf")); !os.IsNotExist(err) {
+		t.Errorf("Expected 50-bii.conf to be removed, got %v", err)
+	}
+}
+
+func TestRemoveFromPathLegacy(t *testing.T) {
+	tmpDir := setTestHome(t)
+
+	// Written by older versions to the interactive config only
+	configPath := filepath.Join(tmpDir, ".zshrc")
+	legacy := "# existing\n\n# Added by bii\nexport PATH=\"/test/bin:$PATH\"\n"
+	if err := os.WriteFile(configPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := RemoveFromPath("zsh", "/test/bin")
+	if err != nil {
+		t.Fatalf("RemoveFromPath failed: %v", err)
+	}
+	if len(changed) != 1 || changed[0] != configPath {
+		t.Errorf("Expected %s to be changed, got %v", configPath, changed)
+	}
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# existing\n" {
+		t.Errorf("Expected legacy line to be removed, got %q", string(content))
+	}
+}
+
+func TestAddToPathIgnoresUnrelatedMentions(t *testing.T) {
+	tmpDir := setTestHome(t)
+
+	configPath := filepath.Join(tmpDir, ".bashrc")
+	if err := os.WriteFile(configPath, []byte("alias tools='ls /test/bin'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AddToPath("bash", "/test/bin"); err != nil {
+		t.Fatalf("AddToPath failed: %v", err)
+	}
+
+	managed, err := ManagedLines("bash")
+	if err != nil {
+		t.Fatalf("ManagedLines failed: %v", err)
+	}
+	if len(managed[configPath]) != 1 {
+		t.Errorf("Expected PATH line to be added despite unrelated mention, got %v", managed[configPath])
+	}
+}
+
+func TestPlanAddToPath(t *testing.T) {
+	originalGOOS := goos
+	defer func() { goos = originalGOOS }()
+	goos = "darwin"
+
+	home := setTestHome(t)
+	configPath := filepath.Join(home, ".zshenv")
+	if err := os.WriteFile(configPath, []byte("# env\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := PlanAddToPath("zsh", "/test/bin")
+	if err != nil {
+		t.Fatalf("PlanAddToPath failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != configPath {
+		t.Fatalf("Expected one change to %s, got %v", configPath, changes)
+	}
+
+	diff := changes[0].Diff()
+	if !strings.Contains(diff, "+"+blockBegin) || !strings.Contains(diff, "export PATH=\"/test/bin:$PATH\"") {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+
+	// Nothing is written
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# env\n" {
+		t.Errorf("Expected config file to be unchanged, got %q", string(content))
+	}
+	if _, err := os.Stat(configPath + backupSuffix); !os.IsNotExist(err) {
+		t.Error("Expected no backup to be written")
+	}
+}
+
+func TestPlanAddToSystemPath(t *testing.T) {
+	originalGOOS := goos
+	defer func() { goos = originalGOOS }()
+
+	etc := setTestEtc(t)
+
+	goos = "linux"
+	change, err := PlanAddToSystemPath("/usr/local/bin")
+	if err != nil {
+		t.Fatalf("PlanAddToSystemPath failed: %v", err)
+	}
+	if change == nil || change.Path != filepath.Join(etc, "profile.d", "bii.sh") {
+		t.Fatalf("Expected change to profile.d, got %v", change)
+	}
+	if !strings.Contains(change.After, blockBegin) || !strings.Contains(change.After, "/usr/local/bin:$PATH") {
+		t.Errorf("Unexpected content:\n%s", change.After)
+	}
+
+	if err := change.apply(); err != nil {
+		t.Fatal(err)
+	}
+	if change, err := PlanAddToSystemPath("/usr/local/bin"); err != nil || change != nil {
+		t.Errorf("Expected no change once added, got %v, %v", change, err)
+	}
+
+	goos = "darwin"
+	change, err = PlanAddToSystemPath("/usr/local/bin")
+	if err != nil {
+		t.Fatalf("PlanAddToSystemPath failed: %v", err)
+	}
+	if change == nil || change.Path != filepath.Join(etc, "paths.d", "bii") || change.After != "/usr/local/bin\n" {
+		t.Fatalf("Expected paths.d entry, got %+v", change)
+	}
+
+	if err := change.apply(); err != nil {
+		t.Fatal(err)
+	}
+	change, err = PlanAddToSystemPath("/opt/bin")
+	if err != nil || change == nil || change.After != "/usr/local/bin\n/opt/bin\n" {
+		t.Errorf("Expected second directory to be appended, got %+v, %v", change, err)
+	}
+	if change, _ := PlanAddToSystemPath("/usr/local/bin"); change != nil {
+		t.Errorf("Expected no change for listed directory, got %+v", change)
+	}
+}
@@ -0,0 +1,141 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Version is the version of the state file format
+const Version = 1
+
+// State records the tools bii installed, so that later runs can tell what
+// changed and what to remove
+type State struct {
+	Version int             `json:"version"`
+	Tools   map[string]Tool `json:"tools"`
+	// Binaries are the binaries bii install installed, by path. Sync
+	// doesn't touch them.
+	Binaries map[string]Binary `json:"binaries,omitempty"`
+}
+
+// Tool is an installed tool
+type Tool struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Version string `json:"version,omitempty"`
+	SHA256  string `json:"sha256"`
+	Dest    string `json:"dest"`
+	// Files are the installed files
+	Files []string `json:"files"`
+	// Spec fingerprints the manifest entry the tool was installed from
+	Spec        string    `json:"spec"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// Binary is a binary installed from an archive with bii install
+type Binary struct {
+	Path    string `json:"path"`
+	Archive string `json:"archive"`
+	// SHA256 is the digest of the archive, if it was read in full
+	SHA256 string `json:"sha256,omitempty"`
+	// Version is what the binary printed when probed, or else the version
+	// in the archive's file name
+	Version     string    `json:"version,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// DefaultPath returns $XDG_STATE_HOME/bii/state.json, defaulting to
+// ~/.local/state/bii/state.json
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "bii", "state.json"), nil
+	}
+	
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "bii", "state.json"), nil
+}
+
+// LockPath returns the file bii locks while it changes the destination,
+// shell configuration or state, next to the state file
+func LockPath() (string, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "lock"), nil
+}
+
+// Load reads the state file. A missing file is an empty state.
+func Load(path string) (*State, error) {
+	s := &State{Version: Version, Tools: make(map[string]Tool), Binaries: make(map[string]Binary)}
+	
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if s.Version > Version {
+		return nil, fmt.Errorf("state file %s was written by a newer bii (version %d)", path, s.Version)
+	}
+	if s.Tools == nil {
+		s.Tools = make(map[string]Tool)
+	}
+	if s.Binaries == nil {
+		s.Binaries = make(map[string]Binary)
+	}
+	
+	return s, nil
+}
+
+// Save writes the state file, replacing it atomically
+func (s *State) Save(path string) error {
+	s.Version = Version
+	
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	
+	return os.Rename(tmp.Name(), path)
+}
+
+// Names returns the names of the installed tools, sorted
+func (s *State) Names() []string {
+	names := make([]string, 0, len(s.Tools))
+	for name := range s.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
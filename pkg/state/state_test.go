@@ -0,0 +1,77 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bii", "state.json")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load of missing file failed: %v", err)
+	}
+	if len(s.Tools) != 0 {
+		t.Fatalf("Expected empty state, got %v", s.Tools)
+	}
+
+	s.Tools["tool"] = Tool{
+		Name:        "tool",
+		Source:      "tool.tar.gz",
+		SHA256:      "abc",
+		Dest:        "/bin",
+		Files:       []string{"/bin/tool"},
+		Spec:        "spec",
+		InstalledAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	s.Tools["another"] = Tool{Name: "another"}
+	s.Binaries["/bin/hello"] = Binary{Path: "/bin/hello", Archive: "/tmp/hello_1.2.0.tar.gz", Version: "1.2.3"}
+	if err := s.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	tool := loaded.Tools["tool"]
+	if tool.Dest != "/bin" || len(tool.Files) != 1 || !tool.InstalledAt.Equal(s.Tools["tool"].InstalledAt) {
+		t.Errorf("Unexpected tool after round trip: %+v", tool)
+	}
+
+	if b := loaded.Binaries["/bin/hello"]; b.Version != "1.2.3" {
+		t.Errorf("Unexpected binary after round trip: %+v", b)
+	}
+
+	names := loaded.Names()
+	if len(names) != 2 || names[0] != "another" || names[1] != "tool" {
+		t.Errorf("Expected sorted names, got %v", names)
+	}
+}
+
+func TestLoadNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "tools": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("Expected error for newer state version")
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+
+	path, err := DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "bii", "state.json") {
+		t.Errorf("Unexpected path %s", path)
+	}
+}
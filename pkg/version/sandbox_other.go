@@ -0,0 +1,11 @@
+//go:build !linux
+
+package version
+
+import "os/exec"
+
+// isolate fails outside Linux, where the binary can't be cut off from the
+// network
+func isolate(cmd *exec.Cmd) error {
+	return ErrNoSandbox
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package version
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killGroup runs cmd in its own process group and kills the whole group
+// when its context is done, so children of the binary don't outlive it
+func killGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
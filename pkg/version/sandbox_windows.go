@@ -0,0 +1,9 @@
+//go:build windows
+
+package version
+
+import "os/exec"
+
+// killGroup does nothing on Windows, where the binary itself is killed when
+// its context is done
+func killGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,164 @@
+// Package version finds out which version of a tool an archive or a binary
+// is: from the archive's file name, or by asking the binary itself
+package version
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeout is how long Probe lets each attempt run
+const DefaultTimeout = 2 * time.Second
+
+// maxOutput is how much of a probe's output is searched for a version
+const maxOutput = 16 << 10
+
+var (
+	// ErrNoVersion is returned by Probe when the binary doesn't print a
+	// version
+	ErrNoVersion = errors.New("no version found")
+	// ErrNoSandbox is returned by Probe where the binary can't be cut off
+	// from the network
+	ErrNoSandbox = errors.New("can't isolate the binary from the network")
+)
+
+// probeArgs are tried in order until one prints a version
+var probeArgs = [][]string{{"--version"}, {"version"}, {"-V"}}
+
+// semver matches a version such as 1.2, v1.2.3 or 1.2.3-rc.1, which must not
+// follow a digit or a dot so that e.g. "x86_64" and "1.2.3.4" aren't cut
+// short. Pre-releases are limited to the usual words, since file names go on
+// with "-linux-amd64".
+var semver = regexp.MustCompile(`(?:^|[^0-9.])v?(\d+\.\d+(?:\.\d+)*(?:-(?:alpha|beta|rc|pre|dev|snapshot)[.0-9A-Za-z]*)?(?:\+[.0-9A-Za-z]+)?)`)
+
+// Parse returns the first version in s, without a leading "v", or "" if
+// there is none
+func Parse(s string) string {
+	m := semver.FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// FromFilename returns the version in the name of an archive, such as
+// "0.121.1" for "hugo_0.121.1_linux-amd64.tar.gz", or "" if there is none
+func FromFilename(name string) string {
+	return Parse(filepath.Base(name))
+}
+
+// Probe runs the binary at path with --version, version and -V in turn and
+// returns the first version it prints. Each attempt is killed after timeout,
+// or DefaultTimeout if it is 0.
+//
+// The binary runs as the current user, in an empty temporary directory
+// with a minimal environment, no stdin and no network. Probe refuses to run
+// it with ErrNoSandbox where the network can't be taken away: outside Linux
+// and where unprivileged user namespaces aren't available.
+func Probe(ctx context.Context, path string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	dir, err := os.MkdirTemp("", "bii-probe-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	var lastErr error
+	for _, args := range probeArgs {
+		out, err := run(ctx, path, args, dir, timeout)
+		if errors.Is(err, ErrNoSandbox) {
+			return "", err
+		}
+		if v := Parse(out); v != "" {
+			return v, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+
+	if lastErr != nil {
+		return "", lastErr
+	}
+	return "", ErrNoVersion
+}
+
+// run runs the binary once and returns its combined output, which is
+// useful even if it fails: some tools print their version with -V and exit 1
+func run(ctx context.Context, path string, args []string, dir string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return runIn(ctx, path, args, dir)
+}
+
+func runIn(ctx context.Context, path string, args []string, dir string) (string, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+		"PATH=/usr/bin:/bin",
+		"LC_ALL=C",
+		"NO_COLOR=1",
+		"TERM=dumb",
+	}
+	out := &limitedBuffer{max: maxOutput}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// Children that keep the output open don't hold up the probe
+	cmd.WaitDelay = timeoutGrace
+
+	killGroup(cmd)
+	if err := isolate(cmd); err != nil {
+		return "", err
+	}
+
+	err := cmd.Run()
+	if err != nil && cmd.Process == nil && isNamespaceErr(err) {
+		return "", fmt.Errorf("%w: %w", ErrNoSandbox, err)
+	}
+	return out.String(), err
+}
+
+// timeoutGrace is how long a probe's output may stay open after it exits or
+// is killed
+const timeoutGrace = 100 * time.Millisecond
+
+// isNamespaceErr reports whether starting a command failed because it
+// couldn't be isolated, rather than because of the binary
+func isNamespaceErr(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.ENOSYS)
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the
+// rest, so a chatty binary can't fill memory
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
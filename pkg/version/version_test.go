@@ -0,0 +1,137 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"kubectl version v1.29.0", "1.29.0"},
+		{"hugo v0.121.1-abc linux/amd64 BuildDate=2023", "0.121.1"},
+		{"go version go1.21.5 linux/amd64", "1.21.5"},
+		{"tool 2.0.0-rc.1 (commit abc)", "2.0.0-rc.1"},
+		{"jq-1.7", "1.7"},
+		{"x86_64", ""},
+		{"no version here", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.in); got != tt.want {
+			t.Errorf("Parse(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFromFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"hugo_0.121.1_linux-amd64.tar.gz", "0.121.1"},
+		{"/tmp/dl/terraform_1.6.0_linux_amd64.zip", "1.6.0"},
+		{"node-v20.10.0-linux-x64.tar.gz", "20.10.0"},
+		{"tool-1.2.3-linux-amd64.tgz", "1.2.3"},
+		{"kubectl.tar.gz", ""},
+		{"-", ""},
+	}
+
+	for _, tt := range tests {
+		if got := FromFilename(tt.name); got != tt.want {
+			t.Errorf("FromFilename(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// script writes an executable shell script to a temporary directory,
+// skipping the test where probes can't run
+func script(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Probing shell scripts needs a unix shell")
+	}
+	requireSandbox(t)
+
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// requireSandbox skips the test where Probe refuses to run binaries
+func requireSandbox(t *testing.T) {
+	t.Helper()
+	if _, err := Probe(context.Background(), "/bin/true", time.Second); errors.Is(err, ErrNoSandbox) {
+		t.Skip("Probes can't be isolated here:", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"--version", `[ "$1" = --version ] && echo "tool v1.4.2"`, "1.4.2"},
+		{"version subcommand", `[ "$1" = version ] && echo "Version: 3.0.1"`, "3.0.1"},
+		{"-V on stderr", `[ "$1" = -V ] && echo "tool 0.9" >&2; exit 1`, "0.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Probe(context.Background(), script(t, tt.body), time.Second)
+			if err != nil {
+				t.Fatalf("Probe failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := Probe(context.Background(), script(t, "echo usage"), time.Second); err != ErrNoVersion {
+		t.Errorf("Expected ErrNoVersion, got %v", err)
+	}
+}
+
+func TestProbeSandbox(t *testing.T) {
+	// The binary runs in an empty directory with a minimal environment
+	t.Setenv("BII_PROBE_SECRET", "leaked")
+	path := script(t, `[ -z "$BII_PROBE_SECRET" ] && [ -z "$(ls -A)" ] && echo 1.0.0`)
+
+	got, err := Probe(context.Background(), path, time.Second)
+	if err != nil || got != "1.0.0" {
+		t.Errorf("Expected 1.0.0 from an isolated probe, got %q, %v", got, err)
+	}
+}
+
+func TestProbeNoSandbox(t *testing.T) {
+	if runtime.GOOS == "linux" {
+		t.Skip("Linux isolates probes where user namespaces are available")
+	}
+
+	if _, err := Probe(context.Background(), os.Args[0], time.Second); !errors.Is(err, ErrNoSandbox) {
+		t.Errorf("Expected ErrNoSandbox, got %v", err)
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	path := script(t, "sleep 10\n")
+
+	start := time.Now()
+	if _, err := Probe(context.Background(), path, 100*time.Millisecond); err == nil {
+		t.Error("Expected a hanging binary to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected each attempt to be killed after the timeout, took %s", elapsed)
+	}
+}